@@ -0,0 +1,39 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// requireAPIKey wraps h so requests must carry key in an X-API-Key header or
+// as an Authorization bearer token, answering 401 otherwise. Requests for the
+// exempt paths, such as health probes, pass through unchecked.
+func requireAPIKey(h http.Handler, key string, exempt ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, path := range exempt {
+			if r.URL.Path == path {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+		if subtle.ConstantTimeCompare([]byte(requestAPIKey(r)), []byte(key)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="lrucache"`)
+			writeError(w, r, "Missing or invalid API key", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// requestAPIKey returns the key presented by r, preferring X-API-Key
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	auth := r.Header.Get("Authorization")
+	if len(auth) > len("Bearer ") && strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		return auth[len("Bearer "):]
+	}
+	return ""
+}
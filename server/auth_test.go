@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireAPIKey(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	c.Set("a", 1, 0)
+	h := NewServerWithOptions(c, ServerOptions{APIKey: "s3cret"})
+
+	tests := []struct {
+		name, header, value string
+		code                int
+	}{
+		{"header", "X-API-Key", "s3cret", http.StatusOK},
+		{"bearer", "Authorization", "Bearer s3cret", http.StatusOK},
+		{"lowercase bearer", "Authorization", "bearer s3cret", http.StatusOK},
+		{"wrong key", "X-API-Key", "guess", http.StatusUnauthorized},
+		{"wrong scheme", "Authorization", "Basic s3cret", http.StatusUnauthorized},
+		{"missing", "", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/get?key=a", nil)
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.code {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.code)
+		}
+		if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: 401 without WWW-Authenticate", tt.name)
+		}
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	probes := requireAPIKey(ok, "s3cret", "/healthz", "/readyz")
+	for _, path := range []string{"/healthz", "/readyz"} {
+		if rec := serve(probes, http.MethodGet, path, ""); rec.Code != http.StatusOK {
+			t.Errorf("%s without a key: status %d, want 200", path, rec.Code)
+		}
+	}
+	if rec := serve(probes, http.MethodGet, "/stats", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("/stats without a key: status %d, want 401", rec.Code)
+	}
+	if rec := serve(NewServerWithOptions(c, ServerOptions{}), http.MethodGet, "/get?key=a", ""); rec.Code != http.StatusOK {
+		t.Errorf("no key configured: status %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// SetMany stores every item under a single write lock, or one per shard in a
+// sharded cache. Each item keeps the value and expiration it was built with.
+func (c *Cache) SetMany(items map[string]CacheItem) {
+	if c.parts != nil {
+		groups := make([]map[string]CacheItem, len(c.parts))
+		for key, item := range items {
+			i := c.partIndex(key)
+			if groups[i] == nil {
+				groups[i] = make(map[string]CacheItem)
+			}
+			groups[i][key] = item
+		}
+		for i, group := range groups {
+			if group != nil {
+				c.parts[i].SetMany(group)
+			}
+		}
+		return
+	}
+	c.lock()
+	defer c.unlock()
+	for key, item := range items {
+		c.put(key, item.value, item.expiration)
+	}
+}
+
+// PreloadEntry is a value to seed the cache with. It is an alias so callers
+// can pass a slice of the equivalent anonymous struct.
+type PreloadEntry = struct {
+	Key   string
+	Value interface{}
+	TTL   time.Duration
+}
+
+// Preload stores entries in order under a single write lock, as if each had
+// been Set, so the usual capacity and budget eviction applies along the way
+// and later entries win when there are more than fit. Unlike Set it does not
+// write through to the backing store, since the values are being seeded.
+func (c *Cache) Preload(entries []PreloadEntry) {
+	if c.parts != nil {
+		groups := make([][]PreloadEntry, len(c.parts))
+		for _, e := range entries {
+			i := c.partIndex(e.Key)
+			groups[i] = append(groups[i], e)
+		}
+		for i, group := range groups {
+			if group != nil {
+				c.parts[i].Preload(group)
+			}
+		}
+		return
+	}
+	c.lock()
+	defer c.unlock()
+	for _, e := range entries {
+		c.set(e.Key, e.Value, e.TTL)
+	}
+	c.saves = nil
+}
+
+// GetMany retrieves the live values for keys under a single write lock, or
+// one per shard in a sharded cache.
+// Missing and expired keys are left out of the result.
+func (c *Cache) GetMany(keys []string) map[string]interface{} {
+	hits, _ := c.GetMultiDetailed(keys)
+	return hits
+}
+
+// GetMultiDetailed retrieves the live values for keys under a single write
+// lock like GetMany, and also lists the keys that were missing or expired in
+// the order they were requested
+func (c *Cache) GetMultiDetailed(keys []string) (hits map[string]interface{}, misses []string) {
+	if c.parts != nil {
+		hits = make(map[string]interface{}, len(keys))
+		for i, group := range c.byPart(keys) {
+			if len(group) == 0 {
+				continue
+			}
+			found, _ := c.parts[i].GetMultiDetailed(group)
+			for key, value := range found {
+				hits[key] = value
+			}
+		}
+		for _, key := range keys {
+			if _, found := hits[key]; !found {
+				misses = append(misses, key)
+			}
+		}
+		return hits, misses
+	}
+	c.lock()
+	defer c.unlock()
+	hits = make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		item, found := c.lookup(key)
+		if !found {
+			c.misses.Add(1)
+			misses = append(misses, key)
+			continue
+		}
+		c.touch(item)
+		c.hits.Add(1)
+		hits[key] = item.load()
+	}
+	return hits, misses
+}
+
+// entry is a single key/value pair in the batch endpoints
+type entry struct {
+	Key        string      `json:"key"`
+	Value      interface{} `json:"value"`
+	Expiration string      `json:"expiration,omitempty"`
+}
+
+// set many keys at once from a JSON array of entries
+func (c *Cache) msetHandler(w http.ResponseWriter, r *http.Request) {
+	var data []entry
+	if err := (jsonCodec{}).Decode(r.Body, &data); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	items := make(map[string]CacheItem, len(data))
+	for _, e := range data {
+		if err := c.CheckKey(e.Key); err != nil {
+			writeError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := c.CheckValueSize(e.Value); err != nil {
+			writeError(w, r, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		expiration, err := c.parseExpiration(e.Expiration)
+		if err != nil {
+			writeError(w, r, "Invalid expiration duration", http.StatusBadRequest)
+			return
+		}
+		items[e.Key] = CacheItem{value: e.Value, expiration: c.expiresAt(expiration)}
+	}
+	if err := c.TrySetMany(items); err != nil {
+		writeSetError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	jsonEncoder(w, r).Encode(map[string]int{"set": len(items)})
+}
+
+// get many keys at once from a JSON array of keys, returning only the live ones.
+// With ?detail=1 the result is an object that also lists the missed keys.
+func (c *Cache) mgetHandler(w http.ResponseWriter, r *http.Request) {
+	var keys []string
+	if err := json.NewDecoder(r.Body).Decode(&keys); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	values, misses := c.GetMultiDetailed(keys)
+	found := make([]entry, 0, len(values))
+	for _, key := range keys {
+		if value, ok := values[key]; ok {
+			found = append(found, entry{Key: key, Value: value})
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Query().Get("detail") != "1" {
+		jsonEncoder(w, r).Encode(found)
+		return
+	}
+	if misses == nil {
+		misses = []string{}
+	}
+	jsonEncoder(w, r).Encode(struct {
+		Items  []entry  `json:"items"`
+		Misses []string `json:"misses"`
+	}{found, misses})
+}
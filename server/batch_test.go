@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSetManyGetMany(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	c.SetMany(map[string]CacheItem{
+		"a": {value: 1, expiration: c.expiresAt(time.Minute)},
+		"b": {value: "two"},
+		"c": {value: 3, expiration: c.expiresAt(50 * time.Millisecond)},
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	got := c.GetMany([]string{"a", "b", "c", "missing"})
+	if want := map[string]interface{}{"a": 1, "b": "two"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestMsetMgetHandlers(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+
+	rec := serve(h, http.MethodPost, "/mset", `[{"key":"a","value":1},{"key":"b","value":"x","expiration":"1m"}]`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("mset: got status %d, body %q", rec.Code, rec.Body.String())
+	}
+	if ttl, ok := c.TTL("b"); !ok || ttl <= 59*time.Second {
+		t.Fatalf("mset ignored the expiration of b: %s, %t", ttl, ok)
+	}
+
+	rec = serve(h, http.MethodPost, "/mget", `["b","missing","a"]`)
+	var found []entry
+	if err := json.Unmarshal(rec.Body.Bytes(), &found); err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 || found[0].Key != "b" || found[1].Key != "a" {
+		t.Fatalf("mget: got %+v, want b then a", found)
+	}
+
+	if rec := serve(h, http.MethodPost, "/mset", `[{"key":"ok","value":1},{"key":"","value":2}]`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("mset with an empty key: got status %d, want 400", rec.Code)
+	}
+	if _, ok := c.Get("ok"); ok {
+		t.Fatal("mset stored part of a rejected batch")
+	}
+}
+
+func TestGetMultiDetailed(t *testing.T) {
+	c := NewCacheWithOptions(Options{SweepInterval: time.Hour})
+	defer c.Close()
+	h := NewServer(c)
+	c.Set("a", 1, 0)
+	c.Set("brief", 2, 10*time.Millisecond)
+	c.Set("c", 3, 0)
+	time.Sleep(20 * time.Millisecond)
+
+	hits, misses := c.GetMultiDetailed([]string{"missing", "a", "brief", "c"})
+	if !reflect.DeepEqual(hits, map[string]interface{}{"a": 1, "c": 3}) {
+		t.Fatalf("hits %v", hits)
+	}
+	if !reflect.DeepEqual(misses, []string{"missing", "brief"}) {
+		t.Fatalf("misses %v, want them in request order", misses)
+	}
+
+	rec := serve(h, http.MethodPost, "/mget?detail=1", `["c","brief","a","missing"]`)
+	var body struct {
+		Items  []entry
+		Misses []string
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Items) != 2 || body.Items[0].Key != "c" || body.Items[1].Key != "a" {
+		t.Fatalf("items %+v, want c then a", body.Items)
+	}
+	if !reflect.DeepEqual(body.Misses, []string{"brief", "missing"}) {
+		t.Fatalf("misses %v", body.Misses)
+	}
+
+	rec = serve(h, http.MethodPost, "/mget?detail=1", `["a"]`)
+	if want := `{"items":[{"key":"a","value":1}],"misses":[]}` + "\n"; rec.Body.String() != want {
+		t.Fatalf("no misses: got %q, want an empty list", rec.Body.String())
+	}
+}
+
+func TestPreloadBeyondCapacity(t *testing.T) {
+	store := newMapStore()
+	c := NewCacheWithOptions(Options{Capacity: 3, Store: store, WriteThrough: true})
+	defer c.Close()
+
+	entries := make([]PreloadEntry, 5)
+	for i := range entries {
+		entries[i] = PreloadEntry{Key: string(rune('a' + i)), Value: i, TTL: time.Minute}
+	}
+	c.Preload(entries)
+	if keys := c.Keys(); !reflect.DeepEqual(keys, []string{"c", "d", "e"}) {
+		t.Fatalf("kept %v, want the last 3 entries", keys)
+	}
+	if got := recency(c); !reflect.DeepEqual(got, []string{"e", "d", "c"}) {
+		t.Fatalf("recency %v, want the latest entry most recent", got)
+	}
+	if ttl, _ := c.TTL("e"); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("e TTL %s", ttl)
+	}
+	if stats := c.Stats(); stats.Evictions != 2 {
+		t.Fatalf("%d evictions, want 2", stats.Evictions)
+	}
+	if len(store.values) != 0 {
+		t.Fatal("Preload wrote through to the store")
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+// DefaultMaxBodyBytes is the largest request body the API reads unless told otherwise
+const DefaultMaxBodyBytes = 1 << 20
+
+// limitBody wraps h so reading more than limit bytes of a request body fails
+func limitBody(h http.Handler, limit int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// writeDecodeError answers a request whose body could not be decoded, with
+// 413 if it was over the size limit and 400 otherwise
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, r, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	writeError(w, r, err.Error(), http.StatusBadRequest)
+}
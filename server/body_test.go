@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLimitBody(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServerWithOptions(c, ServerOptions{MaxBodyBytes: 64})
+
+	small := `{"key":"a","value":"x"}`
+	if rec := serve(h, http.MethodPost, "/set", small); rec.Code != http.StatusCreated {
+		t.Fatalf("small body: status %d, want 201", rec.Code)
+	}
+	big := `{"key":"b","value":"` + strings.Repeat("x", 100) + `"}`
+	for _, path := range []string{"/set", "/mset", "/import"} {
+		rec := serve(h, http.MethodPost, path, big)
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s with a big body: status %d, want 413", path, rec.Code)
+			continue
+		}
+		if message, _ := decodeError(t, rec); message != "Request body too large" {
+			t.Errorf("%s: message %q", path, message)
+		}
+	}
+	if c.Has("b") {
+		t.Fatal("an oversized body was stored")
+	}
+
+	unlimited := NewServerWithOptions(c, ServerOptions{MaxBodyBytes: -1})
+	if rec := serve(unlimited, http.MethodPost, "/set", big); rec.Code != http.StatusCreated {
+		t.Fatalf("no limit: status %d, want 201", rec.Code)
+	}
+}
@@ -0,0 +1,1007 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultCapacity is the maximum number of keys a cache holds unless told otherwise
+const DefaultCapacity = 1024
+
+// DefaultSweepInterval is how often the background process evicts expired items
+const DefaultSweepInterval = 1 * time.Second
+
+// DefaultSweepBatch is how many expired items a sweep evicts before letting
+// other operations take the lock
+const DefaultSweepBatch = 1000
+
+// EvictionPolicy selects which key is evicted when the cache is full
+type EvictionPolicy int
+
+const (
+	// LRU evicts the least recently used key
+	LRU EvictionPolicy = iota
+	// LFU evicts the least frequently used key, the least recently used among
+	// equals. Finding it may scan every key, see evictLeastFrequent.
+	LFU
+)
+
+// Options configures a cache created with NewCacheWithOptions.
+// Zero values fall back to the package defaults.
+type Options struct {
+	Capacity      int            // maximum number of keys
+	SweepInterval time.Duration  // delay between expiration sweeps
+	SweepBatch    int            // expired keys evicted per lock hold during a sweep, DefaultSweepBatch if zero
+	DefaultTTL    time.Duration  // expiration used when a request omits one, none if zero
+	MaxBytes      int64          // budget for the estimated size of all values, unlimited if zero
+	Policy        EvictionPolicy // which key to evict when full, LRU by default
+	MaxKeyLength  int            // longest key in bytes, DefaultMaxKeyLength if zero
+	MaxCost       int64          // budget for the total cost of all items, see SetWithCost; unlimited if zero
+	MaxValueBytes int64          // largest estimated size of a single value, unlimited if zero
+
+	// Compress gzips string and []byte values of at least CompressThreshold
+	// bytes (DefaultCompressThreshold if zero), decompressing them on read
+	Compress          bool
+	CompressThreshold int
+
+	// ExpirationJitter spreads expirations set at the same moment with the same
+	// TTL by randomly moving each one up to this fraction of its TTL earlier
+	// or later, so that they do not all expire at once. Must be in [0, 1).
+	ExpirationJitter float64
+
+	// CheckValues makes SetChecked reject values that cannot be encoded as
+	// JSON, which the HTTP API and snapshots would fail to write out later
+	CheckValues bool
+
+	// LazyExpiration turns off the background sweep, so expired items are only
+	// removed when they are next accessed or pushed out by capacity pressure.
+	// This saves the periodic wakeups on caches that see little traffic.
+	LazyExpiration bool
+
+	// Store is a backing tier that reads fall through to on a miss, caching
+	// what they load. With WriteThrough, every value the cache stores is also
+	// saved to it.
+	// The cache is purely in memory if it is nil.
+	Store        Store
+	WriteThrough bool
+
+	// CacheControl makes /get send Cache-Control max-age and Expires headers
+	// derived from the remaining TTL of the key, so HTTP caches in front of
+	// the server keep a value no longer than the cache does. Keys that never
+	// expire get neither header.
+	CacheControl bool
+
+	// LockMetrics records how long operations wait for and hold the cache
+	// write lock in histograms served on /metrics. It adds two clock reads
+	// to every write, so it is off by default.
+	LockMetrics bool
+
+	// RejectWhenFull keeps live keys from being evicted to make room for a
+	// new one once Capacity is reached. Expired keys are still reclaimed, but
+	// if none can be, TrySet returns ErrCacheFull and Set drops the value.
+	// By default the eviction policy makes room instead.
+	RejectWhenFull bool
+
+	// StatsInterval is how often the counters are sampled into the history
+	// served on /stats/history, which keeps the latest StatsHistory samples
+	// (DefaultStatsHistory if zero). There is no sampling if it is zero.
+	StatsInterval time.Duration
+	StatsHistory  int
+
+	// Shards splits the keys between that many independently locked shards,
+	// chosen by a hash of the key, so that operations on unrelated keys do not
+	// contend for one mutex. Each shard holds an equal share of Capacity,
+	// MaxBytes and MaxCost, rounded up, and evicts from its own keys, so the
+	// eviction policy and RejectWhenFull only apply per shard. Zero or one
+	// keeps a single lock and exact eviction order.
+	Shards int
+
+	// Clock supplies the time that expirations are measured against, the
+	// wall clock if nil. The sweep still runs every SweepInterval of real time.
+	Clock Clock
+
+	// Logger receives eviction records at info level and every Get and Set
+	// at debug level. Nothing is logged if it is nil.
+	Logger *slog.Logger
+}
+
+// CacheItem represents an item in the cache with expiration time
+type CacheItem struct {
+	value      interface{}
+	expiration int64         // Unix time in nanoseconds
+	element    *list.Element // position of the key in the recency list
+	size       int64         // estimated bytes, only tracked when the cache has a byte budget
+	frequency  int64         // number of reads and writes, used by the LFU policy
+	encoding   valueEncoding // how value is stored, see load
+	heapIndex  int           // position in the expiry heap, -1 if not in it
+	tags       []string      // labels set by SetWithTags
+	cost       int64         // capacity units, 1 unless set by SetWithCost
+	accessed   int64         // Unix nanoseconds of the last read or write, see IdleSince
+	createdAt  int64         // Unix nanoseconds of the Set that added the key
+	updatedAt  int64         // Unix nanoseconds of the latest Set of the key
+}
+
+// NoExpiration passed to Set stores a key that is never evicted by the sweep.
+// Any zero or negative expiration behaves the same way.
+const NoExpiration time.Duration = -1
+
+// expiresAt converts a relative expiration into the stored Unix nanosecond
+// deadline, using 0 for keys that never expire
+func (c *Cache) expiresAt(expiration time.Duration) int64 {
+	if expiration <= 0 {
+		return 0
+	}
+	return c.clock.Now().Add(expiration).UnixNano()
+}
+
+// expired reports whether the item is past its expiration time at now
+func (item *CacheItem) expired(now time.Time) bool {
+	return item.expiration != 0 && now.UnixNano() > item.expiration
+}
+
+// Cache represents the cache structure
+type Cache struct {
+	items      map[string]*CacheItem
+	order      *list.List // keys, most recently used at the front
+	expiries   expiryHeap // items with an expiration, soonest first
+	capacity   int
+	sweep      time.Duration
+	sweepBatch int
+	defaultTTL atomic.Int64 // nanoseconds, see SetDefaultTTL
+	maxBytes   int64
+	policy     EvictionPolicy
+	compressAt int // minimum size of compressed values, compression is off if zero
+	logger     *slog.Logger
+	clock      Clock
+	jitter     float64
+	maxKeyLen  int   // longest accepted key in bytes
+	checkVals  bool  // SetChecked also rejects unserializable values
+	bytes      int64 // estimated size of all values when maxBytes is set
+	maxCost    int64
+	maxValue   int64 // largest accepted value size in bytes, unlimited if zero
+	cost       int64 // total cost of all items
+	mutex      sync.RWMutex
+	locks      *lockMetrics // lock timing, nil unless LockMetrics is set
+	store      Store
+	writeThru  bool
+	rejectFull bool // new keys are refused rather than evicting live ones at capacity
+	httpCache  bool // /get sends caching headers, see Options.CacheControl
+
+	hits      atomic.Int64
+	misses    atomic.Int64
+	sets      atomic.Int64
+	evictions atomic.Int64
+
+	stop      chan struct{} // closed by Close to end the eviction loop
+	done      chan struct{} // closed once the eviction loop has returned
+	closeOnce sync.Once
+	sampled   chan struct{} // closed once the stats sampler has returned, see StatsInterval
+
+	history *statsHistory // counter samples, nil unless StatsInterval is set
+
+	expired  tombstones                     // keys recently removed by expiration, see Fetch
+	tagged   map[string]map[string]struct{} // keys carrying each tag, see SetWithTags
+	negative map[string]int64               // keys recorded as absent by SetMiss, with their expiration
+
+	loads singleflight.Group // loader calls in flight for GetOrLoad, by key
+
+	onEvict func(key string, value interface{})
+	evicted []evictedItem // evictions waiting for onEvict once the lock is released
+	saves   []storeSave   // writes waiting to be saved to the store once the lock is released
+	feed    *evictionFeed // subscribers to evictions, see /events
+
+	parts []*Cache // shards holding the items, see Options.Shards; nil if the cache holds them itself
+}
+
+// evictedItem records a key removed by expiration or capacity pressure
+type evictedItem struct {
+	key  string
+	item *CacheItem
+}
+
+// Stats is a snapshot of the cache runtime counters
+type Stats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Size      int   `json:"size"`
+	Bytes     int64 `json:"bytes"`
+	Cost      int64 `json:"cost"`
+}
+
+// NewCache creates a new cache instance holding up to DefaultCapacity keys
+func NewCache() *Cache {
+	return NewCacheWithCapacity(DefaultCapacity)
+}
+
+// NewCacheWithCapacity creates a new cache instance holding up to capacity keys.
+// It panics if capacity is not positive.
+func NewCacheWithCapacity(capacity int) *Cache {
+	if capacity <= 0 {
+		panic(fmt.Sprintf("cache capacity must be positive, got %d", capacity))
+	}
+	return NewCacheWithOptions(Options{Capacity: capacity})
+}
+
+// NewCacheWithOptions creates a new cache instance configured by opts.
+// It panics if the capacity or sweep interval is negative.
+func NewCacheWithOptions(opts Options) *Cache {
+	cache := newCache(opts)
+	if opts.LazyExpiration {
+		// There is no eviction loop for Close to wait on
+		close(cache.done)
+		return cache
+	}
+	go cache.startEvictionProcess()
+	return cache
+}
+
+// newCache builds a cache from opts without starting its eviction process.
+// It does start the stats sampler if StatsInterval is set.
+func newCache(opts Options) *Cache {
+	if opts.Capacity < 0 {
+		panic(fmt.Sprintf("cache capacity must be positive, got %d", opts.Capacity))
+	}
+	if opts.MaxBytes < 0 {
+		panic(fmt.Sprintf("byte budget must be positive, got %d", opts.MaxBytes))
+	}
+	if opts.MaxCost < 0 {
+		panic(fmt.Sprintf("cost budget must be positive, got %d", opts.MaxCost))
+	}
+	if opts.MaxValueBytes < 0 {
+		panic(fmt.Sprintf("maximum value size must be positive, got %d", opts.MaxValueBytes))
+	}
+	if opts.SweepInterval < 0 {
+		panic(fmt.Sprintf("sweep interval must be positive, got %s", opts.SweepInterval))
+	}
+	if opts.SweepBatch < 0 {
+		panic(fmt.Sprintf("sweep batch must be positive, got %d", opts.SweepBatch))
+	}
+	if opts.ExpirationJitter < 0 || opts.ExpirationJitter >= 1 {
+		panic(fmt.Sprintf("expiration jitter must be in [0, 1), got %g", opts.ExpirationJitter))
+	}
+	if opts.MaxKeyLength < 0 {
+		panic(fmt.Sprintf("maximum key length must be positive, got %d", opts.MaxKeyLength))
+	}
+	if opts.CompressThreshold < 0 {
+		panic(fmt.Sprintf("compression threshold must be positive, got %d", opts.CompressThreshold))
+	}
+	if opts.StatsInterval < 0 {
+		panic(fmt.Sprintf("stats interval must be positive, got %s", opts.StatsInterval))
+	}
+	if opts.StatsHistory < 0 {
+		panic(fmt.Sprintf("stats history must be positive, got %d", opts.StatsHistory))
+	}
+	if opts.Shards < 0 {
+		panic(fmt.Sprintf("shard count must be positive, got %d", opts.Shards))
+	}
+	if opts.Capacity == 0 {
+		opts.Capacity = DefaultCapacity
+	}
+	var compressAt int
+	if opts.Compress {
+		compressAt = opts.CompressThreshold
+		if compressAt == 0 {
+			compressAt = DefaultCompressThreshold
+		}
+	}
+	if opts.SweepInterval == 0 {
+		opts.SweepInterval = DefaultSweepInterval
+	}
+	if opts.SweepBatch == 0 {
+		opts.SweepBatch = DefaultSweepBatch
+	}
+	if opts.MaxKeyLength == 0 {
+		opts.MaxKeyLength = DefaultMaxKeyLength
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(discardHandler{})
+	}
+	clock := opts.Clock
+	if clock == nil {
+		clock = realClock{}
+	}
+	var locks *lockMetrics
+	if opts.LockMetrics {
+		locks = newLockMetrics()
+	}
+	cache := &Cache{
+		items:      make(map[string]*CacheItem),
+		order:      list.New(),
+		capacity:   opts.Capacity,
+		sweep:      opts.SweepInterval,
+		sweepBatch: opts.SweepBatch,
+		maxBytes:   opts.MaxBytes,
+		maxCost:    opts.MaxCost,
+		maxValue:   opts.MaxValueBytes,
+		policy:     opts.Policy,
+		compressAt: compressAt,
+		logger:     logger,
+		clock:      clock,
+		jitter:     opts.ExpirationJitter,
+		maxKeyLen:  opts.MaxKeyLength,
+		checkVals:  opts.CheckValues,
+		locks:      locks,
+		store:      opts.Store,
+		writeThru:  opts.WriteThrough,
+		rejectFull: opts.RejectWhenFull,
+		httpCache:  opts.CacheControl,
+		feed:       &evictionFeed{},
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+		sampled:    make(chan struct{}),
+	}
+	cache.defaultTTL.Store(int64(opts.DefaultTTL))
+	if opts.Shards > 1 {
+		cache.split(opts)
+	}
+	if opts.StatsInterval == 0 {
+		close(cache.sampled)
+		return cache
+	}
+	if opts.StatsHistory == 0 {
+		opts.StatsHistory = DefaultStatsHistory
+	}
+	cache.history = newStatsHistory(opts.StatsHistory)
+	go cache.startSampler(opts.StatsInterval)
+	return cache
+}
+
+// new key-value pair to the cache with an expiration time. An expiration of
+// zero, or any negative one such as NoExpiration, stores the key without
+// expiration rather than expiring it at once. Setting a key that
+// is already present replaces its value and expiration and marks it most
+// recently used, so it is the last to be evicted. Keys rejected by CheckKey
+// and values rejected by CheckValueSize are dropped, SetChecked reports them instead.
+func (c *Cache) Set(key string, value interface{}, expiration time.Duration) {
+	if c.parts != nil {
+		c.part(key).Set(key, value, expiration)
+		return
+	}
+	c.lock()
+	c.set(key, value, expiration)
+	c.unlock()
+	c.logger.Debug("cache set", "key", key, "expiration", expiration)
+}
+
+// SetAndGetPrevious stores the value like Set and returns the live value it
+// replaced, if there was one. A value the cache refuses replaces nothing.
+func (c *Cache) SetAndGetPrevious(key string, value interface{}, expiration time.Duration) (previous interface{}, replaced bool) {
+	if c.parts != nil {
+		return c.part(key).SetAndGetPrevious(key, value, expiration)
+	}
+	c.lock()
+	defer c.unlock()
+	item, found := c.lookup(key)
+	if found {
+		previous = item.load()
+	}
+	if !c.set(key, value, expiration) || !found {
+		return nil, false
+	}
+	return previous, true
+}
+
+// SetWithDeadline stores the value like Set, expiring it at deadline rather
+// than after a duration. A zero deadline means no expiration. A deadline that
+// has already passed stores nothing and removes any current value for key.
+func (c *Cache) SetWithDeadline(key string, value interface{}, deadline time.Time) {
+	if c.parts != nil {
+		c.part(key).SetWithDeadline(key, value, deadline)
+		return
+	}
+	c.lock()
+	defer c.unlock()
+	c.setWithDeadline(key, value, deadline)
+}
+
+// setWithDeadline is SetWithDeadline for a caller that holds the write lock
+func (c *Cache) setWithDeadline(key string, value interface{}, deadline time.Time) {
+	if deadline.IsZero() {
+		c.put(key, value, 0)
+		return
+	}
+	if !deadline.After(c.clock.Now()) {
+		if item, found := c.items[key]; found {
+			c.removeItem(key, item)
+		}
+		return
+	}
+	c.put(key, value, deadline.UnixNano())
+}
+
+// set stores the value and marks the key most recently used, evicting the
+// least recently used key when the cache is full. It reports whether the
+// value was stored. The caller must hold the write lock.
+func (c *Cache) set(key string, value interface{}, expiration time.Duration) bool {
+	return c.put(key, value, c.expiresAt(c.applyJitter(expiration)))
+}
+
+// applyJitter randomly moves expiration by up to the configured jitter fraction
+func (c *Cache) applyJitter(expiration time.Duration) time.Duration {
+	if c.jitter == 0 || expiration <= 0 {
+		return expiration
+	}
+	offset := (rand.Float64()*2 - 1) * c.jitter * float64(expiration)
+	return expiration + time.Duration(offset)
+}
+
+// put is set with an absolute Unix nanosecond deadline, 0 meaning no expiration.
+// Keys rejected by CheckKey, values rejected by CheckValueSize and new keys
+// refused by RejectWhenFull are ignored, and put reports false.
+// The caller must hold the write lock.
+func (c *Cache) put(key string, value interface{}, expiration int64) bool {
+	if err := c.CheckKey(key); err != nil {
+		c.logger.Warn("cache set rejected", "error", err)
+		return false
+	}
+	if err := c.CheckValueSize(value); err != nil {
+		c.logger.Warn("cache set rejected", "error", err, "key", key)
+		return false
+	}
+	item, found := c.items[key]
+	if !found && !c.hasRoom() {
+		c.logger.Warn("cache set rejected", "error", ErrCacheFull, "key", key)
+		return false
+	}
+	if found {
+		if now := c.clock.Now(); item.expired(now) {
+			// An expired key the sweep has not removed yet is set afresh
+			item.createdAt = now.UnixNano()
+			item.frequency = 0
+		}
+		c.untag(key, item)
+		c.setExpiration(item, expiration)
+		c.setCost(item, 1)
+		c.update(key, item, value)
+		c.enforceCostBudget()
+		return true
+	}
+	c.sets.Add(1)
+	c.queueSave(key, value, expiration)
+	value, encoding := c.encode(value)
+	var size int64
+	if c.maxBytes > 0 {
+		size = estimateSize(value)
+	}
+	c.expired.forget(key)
+	delete(c.negative, key)
+	if len(c.items) >= c.capacity {
+		c.evictOne(nil)
+	}
+	now := c.clock.Now().UnixNano()
+	item = &CacheItem{
+		value:     value,
+		element:   c.order.PushFront(key),
+		size:      size,
+		frequency: 1,
+		encoding:  encoding,
+		heapIndex: -1,
+		cost:      1,
+		accessed:  now,
+		createdAt: now,
+		updatedAt: now,
+	}
+	c.setExpiration(item, expiration)
+	c.items[key] = item
+	c.bytes += size
+	c.cost++
+	c.enforceByteBudget()
+	c.enforceCostBudget()
+	return true
+}
+
+// update replaces the value of the existing item for key in place, keeping
+// its expiration, tags and cost. The caller must hold the write lock.
+func (c *Cache) update(key string, item *CacheItem, value interface{}) {
+	c.sets.Add(1)
+	c.queueSave(key, value, item.expiration)
+	value, encoding := c.encode(value)
+	var size int64
+	if c.maxBytes > 0 {
+		size = estimateSize(value)
+	}
+	item.value = value
+	item.encoding = encoding
+	c.bytes += size - item.size
+	item.size = size
+	c.touch(item)
+	item.updatedAt = item.accessed
+	c.enforceByteBudget()
+}
+
+// Get Method retrieves the value given key from the cache, falling through
+// to the backing store on a miss if there is one. A stored nil is a hit,
+// returned as nil and true, so only the bool tells a miss apart.
+func (c *Cache) Get(key string) (interface{}, bool) {
+	if c.parts != nil {
+		return c.part(key).Get(key)
+	}
+	value, ok := c.get(key)
+	c.logger.Debug("cache get", "key", key, "hit", ok)
+	if !ok && c.store != nil {
+		value, _, ok = c.readThrough(key)
+	}
+	return value, ok
+}
+
+// get is Get without the debug log
+func (c *Cache) get(key string) (interface{}, bool) {
+	c.lock()
+	defer c.unlock()
+	item, found := c.lookup(key)
+	if !found {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.touch(item)
+	c.hits.Add(1)
+	return item.load(), true
+}
+
+// GetWithExpiry retrieves the value for key like Get along with the moment it
+// expires, which is the zero time for keys that never expire
+func (c *Cache) GetWithExpiry(key string) (value interface{}, expiresAt time.Time, ok bool) {
+	value, meta, ok := c.GetWithMeta(key)
+	return value, meta.ExpiresAt, ok
+}
+
+// ItemMeta describes when a key was written and when it expires
+type ItemMeta struct {
+	ExpiresAt time.Time // zero for keys that never expire
+	CreatedAt time.Time // when the key was first set
+	UpdatedAt time.Time // when the key was last set, CreatedAt if never overwritten
+}
+
+// GetWithMeta retrieves the value for key like Get along with its ItemMeta
+func (c *Cache) GetWithMeta(key string) (value interface{}, meta ItemMeta, ok bool) {
+	if c.parts != nil {
+		return c.part(key).GetWithMeta(key)
+	}
+	c.lock()
+	defer c.unlock()
+	item, found := c.lookup(key)
+	if !found {
+		c.misses.Add(1)
+		return nil, ItemMeta{}, false
+	}
+	c.touch(item)
+	c.hits.Add(1)
+	return item.load(), item.meta(), true
+}
+
+// meta reports the timestamps of item. The caller must hold the lock.
+func (item *CacheItem) meta() ItemMeta {
+	meta := ItemMeta{
+		CreatedAt: time.Unix(0, item.createdAt),
+		UpdatedAt: time.Unix(0, item.updatedAt),
+	}
+	if item.expiration != 0 {
+		meta.ExpiresAt = time.Unix(0, item.expiration)
+	}
+	return meta
+}
+
+// Peek retrieves the value for key without marking it recently used or
+// counting a hit or miss, so inspecting the cache does not change what it evicts
+func (c *Cache) Peek(key string) (interface{}, bool) {
+	if c.parts != nil {
+		return c.part(key).Peek(key)
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	item, found := c.items[key]
+	if !found || item.expired(c.clock.Now()) {
+		return nil, false
+	}
+	return item.load(), true
+}
+
+// Has reports whether key is present and live without returning its value
+// or changing its recency
+func (c *Cache) Has(key string) bool {
+	if c.parts != nil {
+		return c.part(key).Has(key)
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	item, found := c.items[key]
+	return found && !item.expired(c.clock.Now())
+}
+
+// GetAndRefresh retrieves the value for key like Get and, on a hit, pushes its
+// expiration to extend from now. This gives sliding expiration for keys that
+// are read through it; Get keeps the absolute expiration.
+func (c *Cache) GetAndRefresh(key string, extend time.Duration) (interface{}, bool) {
+	if c.parts != nil {
+		return c.part(key).GetAndRefresh(key, extend)
+	}
+	c.lock()
+	defer c.unlock()
+	item, found := c.lookup(key)
+	if !found {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.setExpiration(item, c.expiresAt(extend))
+	c.touch(item)
+	c.hits.Add(1)
+	return item.load(), true
+}
+
+// GetEx retrieves the value for key and gives it a new TTL counted from now
+// in the same locked step, like the Redis GETEX command. A zero or negative
+// newTTL removes the expiration so the key persists.
+func (c *Cache) GetEx(key string, newTTL time.Duration) (interface{}, bool) {
+	return c.GetAndRefresh(key, newTTL)
+}
+
+// GetOrSet returns the live value for key if there is one (loaded is true).
+// Otherwise it stores value and returns it (loaded is false). Both happen under
+// a single write lock so concurrent callers agree on the winning value. If the
+// cache refuses to store value, nothing is returned but the error TrySet
+// would give for it.
+func (c *Cache) GetOrSet(key string, value interface{}, expiration time.Duration) (actual interface{}, loaded bool, err error) {
+	if c.parts != nil {
+		return c.part(key).GetOrSet(key, value, expiration)
+	}
+	c.lock()
+	defer c.unlock()
+	if item, found := c.lookup(key); found {
+		c.touch(item)
+		c.hits.Add(1)
+		return item.load(), true, nil
+	}
+	c.misses.Add(1)
+	if err := c.admit(key, value); err != nil {
+		return nil, false, err
+	}
+	c.set(key, value, expiration)
+	return value, false, nil
+}
+
+// Clear removes every item. The hit, miss and eviction counters are left
+// untouched so they keep describing the lifetime of the cache, and the
+// removed items are not reported as evictions.
+func (c *Cache) Clear() {
+	if c.parts != nil {
+		for _, part := range c.parts {
+			part.Clear()
+		}
+		return
+	}
+	c.lock()
+	defer c.unlock()
+	c.items = make(map[string]*CacheItem)
+	c.order.Init()
+	c.expiries = nil
+	c.expired = tombstones{}
+	c.tagged = nil
+	c.negative = nil
+	c.bytes = 0
+	c.cost = 0
+}
+
+// TTL returns the time remaining before key expires and whether key is live.
+// Keys that never expire report NoExpiration.
+func (c *Cache) TTL(key string) (time.Duration, bool) {
+	if c.parts != nil {
+		return c.part(key).TTL(key)
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	item, found := c.items[key]
+	if !found {
+		return 0, false
+	}
+	now := c.clock.Now()
+	if item.expired(now) {
+		return 0, false
+	}
+	if item.expiration == 0 {
+		return NoExpiration, true
+	}
+	return time.Duration(item.expiration - now.UnixNano()), true
+}
+
+// UpdateTTL gives a live key a new expiration, counted from now, without
+// changing its value; zero or less removes its expiration as with Set. It
+// returns false if the key is missing or expired.
+func (c *Cache) UpdateTTL(key string, expiration time.Duration) bool {
+	if c.parts != nil {
+		return c.part(key).UpdateTTL(key, expiration)
+	}
+	c.lock()
+	defer c.unlock()
+	item, found := c.lookup(key)
+	if !found {
+		return false
+	}
+	c.setExpiration(item, c.expiresAt(expiration))
+	return true
+}
+
+// lookup returns the item for key if it is live, evicting it when it has
+// expired. It does not touch the recency list. The caller must hold the write lock.
+func (c *Cache) lookup(key string) (*CacheItem, bool) {
+	item, found := c.items[key]
+	if !found {
+		return nil, false
+	}
+	if item.expired(c.clock.Now()) {
+		// Evict expired item
+		c.evictExpired(key, item)
+		return nil, false
+	}
+	return item, true
+}
+
+// Delete removes the key from the cache and reports whether it was present
+func (c *Cache) Delete(key string) bool {
+	if c.parts != nil {
+		return c.part(key).Delete(key)
+	}
+	c.lock()
+	defer c.unlock()
+	delete(c.negative, key)
+	item, found := c.items[key]
+	if !found {
+		return false
+	}
+	c.removeItem(key, item)
+	return true
+}
+
+// Len returns the number of live items, ignoring expired items the sweep has not removed yet
+func (c *Cache) Len() int {
+	if c.parts != nil {
+		total := 0
+		for _, part := range c.parts {
+			total += part.Len()
+		}
+		return total
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.liveLen(c.clock.Now())
+}
+
+// liveLen counts the items not expired at now. The caller must hold the lock.
+func (c *Cache) liveLen(now time.Time) int {
+	count := 0
+	for _, item := range c.items {
+		if !item.expired(now) {
+			count++
+		}
+	}
+	return count
+}
+
+// Stats returns the current hit, miss and eviction counters along with the live size
+// and the estimated byte and cost usage
+func (c *Cache) Stats() Stats {
+	if c.parts != nil {
+		var total Stats
+		for _, part := range c.parts {
+			stats := part.Stats()
+			total.Hits += stats.Hits
+			total.Misses += stats.Misses
+			total.Evictions += stats.Evictions
+			total.Size += stats.Size
+			total.Bytes += stats.Bytes
+			total.Cost += stats.Cost
+		}
+		return total
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Size:      c.liveLen(c.clock.Now()),
+		Bytes:     c.bytes,
+		Cost:      c.cost,
+	}
+}
+
+// DefaultTTL returns the expiration used when an HTTP or text protocol set omits one
+func (c *Cache) DefaultTTL() time.Duration {
+	return time.Duration(c.defaultTTL.Load())
+}
+
+// SetDefaultTTL changes the expiration used when an HTTP or text protocol
+// set omits one, zero for none. Keys already stored keep their expiration.
+func (c *Cache) SetDefaultTTL(ttl time.Duration) {
+	c.defaultTTL.Store(int64(ttl))
+}
+
+// removeItem drops key from both the map and the recency list.
+// The caller must hold the write lock.
+func (c *Cache) removeItem(key string, item *CacheItem) {
+	c.order.Remove(item.element)
+	c.unschedule(item)
+	c.untag(key, item)
+	delete(c.items, key)
+	c.bytes -= item.size
+	c.cost -= item.cost
+}
+
+// evict removes an expired or least recently used item and queues it for the
+// eviction callback. The caller must hold the write lock.
+func (c *Cache) evict(key string, item *CacheItem) {
+	c.removeItem(key, item)
+	c.evictions.Add(1)
+	c.evicted = append(c.evicted, evictedItem{key: key, item: item})
+}
+
+// evictExpired evicts an item that has expired, remembering its key for
+// Fetch. The caller must hold the write lock.
+func (c *Cache) evictExpired(key string, item *CacheItem) {
+	c.evict(key, item)
+	c.expired.bury(key, c.capacity)
+}
+
+// unlock releases the write lock taken with lock and then logs and runs the eviction callback
+// for everything evicted while it was held, so the callback may use the cache.
+// Values stored while it was held are saved to the store afterwards too.
+func (c *Cache) unlock() {
+	fn, evicted := c.onEvict, c.evicted
+	c.evicted = nil
+	saves := c.saves
+	c.saves = nil
+	if c.locks != nil {
+		c.locks.hold.Observe(time.Since(c.locks.lockedAt).Seconds())
+	}
+	c.mutex.Unlock()
+	c.writeThrough(saves)
+	for _, e := range evicted {
+		c.logger.Info("cache eviction", "key", e.key)
+		if fn != nil {
+			fn(e.key, e.item.load())
+		}
+	}
+	c.publishEvicted(evicted)
+}
+
+// OnEvict registers fn to be called for every item evicted by expiration or
+// by LRU capacity pressure. Explicit deletes do not trigger it. fn runs after
+// the cache lock is released, replacing any previously registered callback.
+func (c *Cache) OnEvict(fn func(key string, value interface{})) {
+	for _, part := range c.parts {
+		part.OnEvict(fn)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onEvict = fn
+}
+
+// touch records a use of item, marking it most recently used, counting it
+// for the LFU policy and noting when it happened. The caller must hold the write lock.
+func (c *Cache) touch(item *CacheItem) {
+	c.order.MoveToFront(item.element)
+	item.frequency++
+	item.accessed = c.clock.Now().UnixNano()
+}
+
+// evictOne makes room for a new item according to the eviction policy, never
+// choosing keep. The caller must hold the write lock.
+func (c *Cache) evictOne(keep *list.Element) {
+	if c.policy == LFU {
+		c.evictLeastFrequent(keep)
+		return
+	}
+	c.evictOldest()
+}
+
+// evictLeastFrequent removes the key with the lowest use count other than keep,
+// preferring the least recently used one on ties. The caller must hold the write lock.
+//
+// The counts are not kept in order, so this walks the recency list from the
+// least recently used end and costs O(n) per eviction in the worst case. The
+// walk stops at the first key used only once, since no key can have a lower
+// count, which keeps it short while new keys are coming and going. That is
+// cheap at DefaultCapacity; caches of many thousands of keys under constant
+// eviction should prefer LRU.
+func (c *Cache) evictLeastFrequent(keep *list.Element) {
+	var victim *list.Element
+	var lowest int64
+	for e := c.order.Back(); e != nil; e = e.Prev() {
+		if e == keep {
+			continue
+		}
+		if item := c.items[e.Value.(string)]; victim == nil || item.frequency < lowest {
+			victim, lowest = e, item.frequency
+		}
+		if lowest <= 1 {
+			break
+		}
+	}
+	if victim == nil {
+		return
+	}
+	key := victim.Value.(string)
+	c.evict(key, c.items[key])
+}
+
+// evictOldest removes the least recently used key. The caller must hold the write lock.
+func (c *Cache) evictOldest() {
+	oldest := c.order.Back()
+	if oldest == nil {
+		return
+	}
+	key := oldest.Value.(string)
+	c.evict(key, c.items[key])
+}
+
+// evicts expired items from the cache, visiting only the ones that are due,
+// and returns how many were evicted. The lock is released after every
+// sweepBatch evictions so that a mass expiry does not stall other operations.
+func (c *Cache) evictExpiredItems() int {
+	if c.parts != nil {
+		total := 0
+		for _, part := range c.parts {
+			total += part.evictExpiredItems()
+		}
+		return total
+	}
+	now := c.clock.Now()
+	total := 0
+	for {
+		c.lock()
+		evicted := c.evictDue(now, c.sweepBatch)
+		c.unlock()
+		total += evicted
+		if evicted < c.sweepBatch {
+			return total
+		}
+	}
+}
+
+// evictDue evicts up to limit items that have expired by now, or all of them
+// if limit is zero, and returns how many it evicted. The caller must hold the write lock.
+func (c *Cache) evictDue(now time.Time, limit int) int {
+	evicted := 0
+	for len(c.expiries) > 0 && c.expiries[0].expired(now) && (limit == 0 || evicted < limit) {
+		item := c.expiries[0]
+		c.evictExpired(item.element.Value.(string), item)
+		evicted++
+	}
+	return evicted
+}
+
+// startEvictionProcess periodically evicts expired items from the cache until Close is called.
+// NewCache runs it in its own goroutine, so it must not spawn another one.
+func (c *Cache) startEvictionProcess() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.sweep)
+	defer ticker.Stop()
+	for {
+		c.evictExpiredItems()
+		select {
+		case <-ticker.C:
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// Close stops the background eviction process and the stats sampler and
+// waits for them to exit, so once it returns no goroutine started by the
+// cache is left running. The cache stays usable, relying on lazy expiration.
+// It is safe to call more than once.
+func (c *Cache) Close() {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+	})
+	<-c.done
+	<-c.sampled
+}
@@ -0,0 +1,659 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recency lists the keys of c most recently used first
+func recency(c *Cache) []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	keys := make([]string, 0, c.order.Len())
+	for e := c.order.Front(); e != nil; e = e.Next() {
+		keys = append(keys, e.Value.(string))
+	}
+	return keys
+}
+
+func TestSetEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	for i := 0; i <= DefaultCapacity; i++ {
+		c.Set(strconv.Itoa(i), i, time.Minute)
+	}
+	if len(c.items) != DefaultCapacity {
+		t.Fatalf("got %d keys, want %d", len(c.items), DefaultCapacity)
+	}
+	if _, ok := c.Get("0"); ok {
+		t.Fatal("the least recently used key was not evicted")
+	}
+	for i := 1; i <= DefaultCapacity; i++ {
+		if _, ok := c.Get(strconv.Itoa(i)); !ok {
+			t.Fatalf("key %d was evicted", i)
+		}
+	}
+}
+
+func TestGetMarksKeyRecentlyUsed(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	for i := 0; i < DefaultCapacity; i++ {
+		c.Set(strconv.Itoa(i), i, time.Minute)
+	}
+	c.Get("0")
+	c.Set("new", 0, time.Minute)
+	if _, ok := c.Get("1"); ok {
+		t.Fatal("1 should have been evicted as the least recently used key")
+	}
+	for _, key := range []string{"0", "2", "new"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	c.Set("a", 1, time.Minute)
+	if !c.Delete("a") {
+		t.Fatal("Delete of a present key reported false")
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("deleted key is still present")
+	}
+	if c.Delete("a") {
+		t.Fatal("Delete of a missing key reported true")
+	}
+	if len(c.items) != 0 || c.order.Len() != 0 || len(c.expiries) != 0 {
+		t.Fatalf("Delete left %d items, %d list entries, %d heap entries", len(c.items), c.order.Len(), len(c.expiries))
+	}
+}
+
+func TestNewCacheWithCapacity(t *testing.T) {
+	c := NewCacheWithCapacity(2)
+	defer c.Close()
+
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Minute)
+	c.Set("c", 3, time.Minute)
+	_, hasA := c.items["a"]
+	_, hasB := c.items["b"]
+	_, hasC := c.items["c"]
+	if len(c.items) != 2 || hasA || !hasB || !hasC {
+		t.Fatalf("got a=%v b=%v c=%v with %d keys, want b and c", hasA, hasB, hasC, len(c.items))
+	}
+
+	for _, capacity := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewCacheWithCapacity(%d) did not panic", capacity)
+				}
+			}()
+			NewCacheWithCapacity(capacity).Close()
+		}()
+	}
+}
+
+func TestLenSkipsExpiredKeys(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Minute)
+	if c.Len() != 2 {
+		t.Fatalf("got %d, want 2", c.Len())
+	}
+	// Plant an item that expired before the sweep could see it.
+	c.mutex.Lock()
+	c.items["c"] = &CacheItem{value: 3, expiration: time.Now().Add(-time.Minute).UnixNano(), element: c.order.PushFront("c")}
+	c.mutex.Unlock()
+	if c.Len() != 2 {
+		t.Fatalf("got %d with c expired, want 2", c.Len())
+	}
+}
+
+func TestGetOrSetConcurrent(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	const callers = 50
+	var wg sync.WaitGroup
+	results := make([]interface{}, callers)
+	stored := make([]bool, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var loaded bool
+			results[i], loaded, _ = c.GetOrSet("k", i, time.Minute)
+			stored[i] = !loaded
+		}(i)
+	}
+	wg.Wait()
+
+	winners := 0
+	for i := range stored {
+		if stored[i] {
+			winners++
+		}
+	}
+	if winners != 1 {
+		t.Fatalf("%d callers stored their value, want 1", winners)
+	}
+	want, _ := c.Get("k")
+	for i, got := range results {
+		if got != want {
+			t.Fatalf("caller %d got %v, want the stored %v", i, got, want)
+		}
+	}
+}
+
+func TestTTL(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	c.Set("fresh", 1, time.Minute)
+	if ttl, ok := c.TTL("fresh"); !ok || ttl <= 58*time.Second || ttl > time.Minute {
+		t.Errorf("fresh: got %s, %t", ttl, ok)
+	}
+	if _, ok := c.TTL("missing"); ok {
+		t.Error("missing key reported a TTL")
+	}
+}
+
+func TestSubSecondExpiration(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	c.Set("a", 1, 200*time.Millisecond)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("key expired before its 200ms TTL")
+	}
+	time.Sleep(300 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("key outlived its 200ms TTL")
+	}
+}
+
+func TestPermanentKeySurvivesSweep(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	c.Set("forever", 1, NoExpiration)
+	c.Set("zero", 2, 0)
+	c.Set("brief", 3, 100*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	c.evictExpiredItems()
+	if ttl, ok := c.TTL("forever"); !ok || ttl != NoExpiration {
+		t.Errorf("forever: got %s, %t", ttl, ok)
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	_, hasForever := c.items["forever"]
+	_, hasZero := c.items["zero"]
+	_, hasBrief := c.items["brief"]
+	if !hasForever || !hasZero || hasBrief {
+		t.Fatalf("got forever=%v zero=%v brief=%v, want forever and zero", hasForever, hasZero, hasBrief)
+	}
+}
+
+func TestCloseStopsEvictionLoop(t *testing.T) {
+	c := NewCache()
+	c.Close()
+	select {
+	case <-c.done:
+	default:
+		t.Fatal("eviction loop still running after Close")
+	}
+	c.Close()
+
+	c.Set("a", 1, time.Minute)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("cache unusable after Close")
+	}
+}
+
+func TestSweepInterval(t *testing.T) {
+	c := NewCacheWithOptions(Options{SweepInterval: 5 * time.Millisecond})
+	defer c.Close()
+
+	c.Set("a", 1, 10*time.Millisecond)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		c.mutex.RLock()
+		n := len(c.items)
+		c.mutex.RUnlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired key was not swept within 2s")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("negative sweep interval did not panic")
+		}
+	}()
+	NewCacheWithOptions(Options{SweepInterval: -time.Second})
+}
+
+// goroutinesStartedBy counts the running goroutines started by function of
+// this package, whether or not they have been scheduled yet
+func goroutinesStartedBy(function string) int {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return strings.Count(string(buf[:n]), "/server."+function+" in goroutine")
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+func TestOneEvictionGoroutinePerCache(t *testing.T) {
+	before := goroutinesStartedBy("NewCacheWithOptions")
+	caches := make([]*Cache, 5)
+	for i := range caches {
+		caches[i] = NewCache()
+		for j := 0; j < 100; j++ {
+			caches[i].Set(strconv.Itoa(j), j, time.Millisecond)
+		}
+	}
+	if got := goroutinesStartedBy("NewCacheWithOptions") - before; got != len(caches) {
+		t.Fatalf("got %d eviction goroutines for %d caches", got, len(caches))
+	}
+	for _, c := range caches {
+		c.Close()
+	}
+	// A loop may still be returning from its deferred close when Close does
+	deadline := time.Now().Add(time.Second)
+	for goroutinesStartedBy("NewCacheWithOptions") > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := goroutinesStartedBy("NewCacheWithOptions") - before; got != 0 {
+		t.Fatalf("%d eviction goroutines left after Close", got)
+	}
+}
+
+func TestGetAndRefreshSlidesExpiration(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	c.Set("sliding", 1, 200*time.Millisecond)
+	c.Set("absolute", 2, 200*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		time.Sleep(120 * time.Millisecond)
+		if _, ok := c.GetAndRefresh("sliding", 200*time.Millisecond); !ok {
+			t.Fatalf("sliding key expired after read %d", i)
+		}
+		c.Get("absolute")
+	}
+	if _, ok := c.Get("absolute"); ok {
+		t.Fatal("Get extended an absolute expiration")
+	}
+	if ttl, _ := c.TTL("sliding"); ttl <= 100*time.Millisecond || ttl > 200*time.Millisecond {
+		t.Fatalf("sliding key has %s left, want close to 200ms", ttl)
+	}
+	if _, ok := c.GetAndRefresh("missing", time.Second); ok {
+		t.Fatal("GetAndRefresh reported a missing key")
+	}
+}
+
+func TestOnEvict(t *testing.T) {
+	c := NewCacheWithOptions(Options{Capacity: 2, SweepInterval: time.Hour})
+	defer c.Close()
+
+	type eviction struct {
+		key   string
+		value interface{}
+	}
+	var (
+		mu  sync.Mutex
+		got []eviction
+	)
+	c.OnEvict(func(key string, value interface{}) {
+		mu.Lock()
+		got = append(got, eviction{key, value})
+		mu.Unlock()
+		// The callback runs without the lock, so it may use the cache
+		c.Len()
+	})
+
+	c.Set("brief", "x", 50*time.Millisecond)
+	c.Set("kept", "y", 0)
+	time.Sleep(100 * time.Millisecond)
+	c.evictExpiredItems()
+	c.Set("a", 1, 0)
+	c.Set("b", 2, 0)
+	c.Delete("b")
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []eviction{{"brief", "x"}, {"kept", "y"}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("got evictions %v, want %v", got, want)
+	}
+}
+
+func TestClear(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, 0)
+	c.Get("a")
+	c.Clear()
+	if c.Len() != 0 || c.order.Len() != 0 || len(c.expiries) != 0 {
+		t.Fatalf("Clear left %d keys", c.Len())
+	}
+	if stats := c.Stats(); stats.Hits != 1 || stats.Evictions != 0 {
+		t.Fatalf("Clear changed the counters: %+v", stats)
+	}
+	c.Set("c", 3, 0)
+	if _, ok := c.Get("c"); !ok {
+		t.Fatal("cache unusable after Clear")
+	}
+}
+
+func TestPeekLeavesRecencyAlone(t *testing.T) {
+	c := NewCacheWithCapacity(2)
+	defer c.Close()
+
+	c.Set("old", 1, 0)
+	c.Set("new", 2, 0)
+	if value, ok := c.Peek("old"); !ok || value != 1 {
+		t.Fatalf("got %v, %t", value, ok)
+	}
+	if stats := c.Stats(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Fatalf("Peek counted a hit or miss: %+v", stats)
+	}
+	c.Set("newest", 3, 0)
+	if _, ok := c.Peek("old"); ok {
+		t.Fatal("a peeked key was not first in line for eviction")
+	}
+}
+
+func TestUpdateTTL(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	c.Set("a", 1, 100*time.Millisecond)
+	if !c.UpdateTTL("a", 300*time.Millisecond) {
+		t.Fatal("UpdateTTL of a live key reported false")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if value, ok := c.Get("a"); !ok || value != 1 {
+		t.Fatal("key did not outlive its original deadline")
+	}
+	if !c.UpdateTTL("a", 0) {
+		t.Fatal("UpdateTTL to no expiration reported false")
+	}
+	if ttl, ok := c.TTL("a"); !ok || ttl != NoExpiration {
+		t.Fatalf("key made permanent has TTL %s, %t", ttl, ok)
+	}
+	if c.UpdateTTL("missing", time.Second) {
+		t.Fatal("UpdateTTL of a missing key reported true")
+	}
+}
+
+func TestSetAndGetPrevious(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	if previous, replaced := c.SetAndGetPrevious("a", 1, 100*time.Millisecond); replaced || previous != nil {
+		t.Fatalf("new key: got %v, %t", previous, replaced)
+	}
+	if previous, replaced := c.SetAndGetPrevious("a", 2, 100*time.Millisecond); !replaced || previous != 1 {
+		t.Fatalf("live key: got %v, %t; want 1", previous, replaced)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if previous, replaced := c.SetAndGetPrevious("a", 3, 0); replaced || previous != nil {
+		t.Fatalf("expired key: got %v, %t; want nothing replaced", previous, replaced)
+	}
+	if value, _ := c.Get("a"); value != 3 {
+		t.Fatalf("got %v, want 3", value)
+	}
+}
+
+func TestSetAndGetPreviousRefused(t *testing.T) {
+	store := newMapStore()
+	c := NewCacheWithOptions(Options{MaxValueBytes: 8, Store: store, WriteThrough: true})
+	defer c.Close()
+	c.SetAndGetPrevious("a", "small", 0)
+
+	if previous, replaced := c.SetAndGetPrevious("a", strings.Repeat("x", 100), 0); replaced || previous != nil {
+		t.Fatalf("oversized value: got %v, %t; want nothing replaced", previous, replaced)
+	}
+	if value, _ := c.Get("a"); value != "small" {
+		t.Fatalf("got %v, want the value that was kept", value)
+	}
+	if store.values["a"] != "small" {
+		t.Fatalf("store holds %v, want only the stored value written through", store.values["a"])
+	}
+}
+
+func TestHas(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	c.Set("live", 1, time.Minute)
+	c.Set("brief", 2, 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	for key, want := range map[string]bool{"live": true, "brief": false, "absent": false} {
+		if got := c.Has(key); got != want {
+			t.Errorf("Has(%q): got %t, want %t", key, got, want)
+		}
+	}
+	if stats := c.Stats(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Fatalf("Has counted a hit or miss: %+v", stats)
+	}
+}
+
+func TestExpirationJitter(t *testing.T) {
+	c := NewCacheWithOptions(Options{ExpirationJitter: 0.2})
+	defer c.Close()
+
+	distinct := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		c.Set(key, i, 10*time.Second)
+		ttl, _ := c.TTL(key)
+		if ttl < 8*time.Second || ttl > 12*time.Second {
+			t.Fatalf("TTL %s outside 10s ± 20%%", ttl)
+		}
+		distinct[ttl] = true
+	}
+	if len(distinct) < 50 {
+		t.Fatalf("only %d distinct TTLs across 100 keys", len(distinct))
+	}
+
+	c.Set("forever", 1, 0)
+	if ttl, _ := c.TTL("forever"); ttl != NoExpiration {
+		t.Fatalf("jitter gave a permanent key the TTL %s", ttl)
+	}
+
+	for _, jitter := range []float64{-0.1, 1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("jitter %g did not panic", jitter)
+				}
+			}()
+			NewCacheWithOptions(Options{ExpirationJitter: jitter}).Close()
+		}()
+	}
+}
+
+func TestGetEx(t *testing.T) {
+	c := NewCacheWithOptions(Options{SweepInterval: time.Hour})
+	defer c.Close()
+
+	c.Set("a", 1, time.Second)
+	if value, ok := c.GetEx("a", time.Hour); !ok || value != 1 {
+		t.Fatalf("GetEx: got %v, %v", value, ok)
+	}
+	if ttl, _ := c.TTL("a"); ttl <= time.Hour-time.Second || ttl > time.Hour {
+		t.Fatalf("TTL after GetEx %s, want 1h", ttl)
+	}
+	if _, ok := c.GetEx("a", 0); !ok {
+		t.Fatal("GetEx without a TTL missed")
+	}
+	if ttl, _ := c.TTL("a"); ttl != NoExpiration {
+		t.Fatalf("GetEx with no TTL left %s, want the key to persist", ttl)
+	}
+
+	c.Set("gone", 2, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := c.GetEx("gone", time.Hour); ok {
+		t.Fatal("GetEx revived an expired key")
+	}
+}
+
+func TestLazyExpiration(t *testing.T) {
+	before := goroutinesStartedBy("NewCacheWithOptions")
+	c := NewCacheWithOptions(Options{LazyExpiration: true})
+	defer c.Close()
+	if got := goroutinesStartedBy("NewCacheWithOptions") - before; got != 0 {
+		t.Fatalf("lazy cache started %d goroutines", got)
+	}
+
+	c.Set("a", 1, 10*time.Millisecond)
+	c.Set("b", 2, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	c.mutex.RLock()
+	stored := len(c.items)
+	c.mutex.RUnlock()
+	if stored != 2 {
+		t.Fatalf("%d items stored before any read, want both expired items kept", stored)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get returned an expired value")
+	}
+	c.mutex.RLock()
+	_, kept := c.items["a"]
+	c.mutex.RUnlock()
+	if kept {
+		t.Fatal("reading an expired key did not remove it")
+	}
+	if c.Len() != 0 {
+		t.Fatalf("Len counts %d expired items", c.Len())
+	}
+}
+
+func TestSetAgainRefreshesRecency(t *testing.T) {
+	c := NewCacheWithCapacity(3)
+	defer c.Close()
+	c.Set("a", 1, 0)
+	c.Set("b", 2, 0)
+	c.Set("c", 3, 0)
+
+	c.Set("a", 10, 0)
+	c.Set("d", 4, 0)
+	if c.Has("b") || !c.Has("a") {
+		t.Fatalf("re-set a was evicted instead of b, most recent first: %v", recency(c))
+	}
+	if value, _ := c.Get("a"); value != 10 {
+		t.Fatalf("a = %v, want the new value", value)
+	}
+	if got := recency(c); !reflect.DeepEqual(got, []string{"a", "d", "c"}) {
+		t.Fatalf("recency %v, want [a d c]", got)
+	}
+}
+
+func TestZeroTTLNeverExpires(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+
+	c.Set("zero", 1, 0)
+	c.Set("negative", 2, -time.Second)
+	serve(h, http.MethodGet, "/set?key=form&value=3&ttl=0s", "")
+	c.evictExpiredItems()
+
+	for _, key := range []string{"zero", "negative", "form"} {
+		if !c.Has(key) {
+			t.Errorf("%s expired", key)
+		}
+		if ttl, _ := c.TTL(key); ttl != NoExpiration {
+			t.Errorf("%s: TTL %s, want NoExpiration", key, ttl)
+		}
+	}
+	if len(c.expiries) != 0 {
+		t.Fatalf("%d permanent keys are waiting in the expiry heap", len(c.expiries))
+	}
+}
+
+func TestGetWithMetaTimestamps(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	start := time.Now()
+	c.Set("k", 1, time.Hour)
+	time.Sleep(10 * time.Millisecond)
+	c.Get("k")
+	_, meta, ok := c.GetWithMeta("k")
+	if !ok || meta.CreatedAt.Before(start) || !meta.UpdatedAt.Equal(meta.CreatedAt) {
+		t.Fatalf("before any overwrite: got %+v, want both times at the first set", meta)
+	}
+	if ttl := meta.ExpiresAt.Sub(meta.CreatedAt); ttl < time.Hour-time.Millisecond || ttl > time.Hour {
+		t.Fatalf("ExpiresAt %s, want an hour after %s", meta.ExpiresAt, meta.CreatedAt)
+	}
+	created := meta.CreatedAt
+
+	c.Set("k", 2, 0)
+	_, meta, _ = c.GetWithMeta("k")
+	if !meta.CreatedAt.Equal(created) || meta.UpdatedAt.Sub(created) < 10*time.Millisecond || !meta.ExpiresAt.IsZero() {
+		t.Fatalf("after an overwrite: got %+v", meta)
+	}
+
+	c.Delete("k")
+	time.Sleep(10 * time.Millisecond)
+	c.Set("k", 3, 0)
+	if _, meta, _ = c.GetWithMeta("k"); meta.CreatedAt.Sub(created) < 20*time.Millisecond {
+		t.Fatalf("a key set again after Delete kept CreatedAt %s", meta.CreatedAt)
+	}
+	if _, _, ok := c.GetWithMeta("missing"); ok {
+		t.Fatal("GetWithMeta found a missing key")
+	}
+}
+
+func TestSetOverExpiredKeyStartsAfresh(t *testing.T) {
+	start := time.Now()
+	clock := newFakeClock(start)
+	c := NewCacheWithOptions(Options{Clock: clock, LazyExpiration: true})
+	defer c.Close()
+
+	c.Set("k", 1, time.Second)
+	for i := 0; i < 5; i++ {
+		c.Get("k")
+	}
+	clock.Advance(2 * time.Second)
+	c.Set("k", 2, 0)
+
+	_, meta, ok := c.GetWithMeta("k")
+	if !ok || !meta.CreatedAt.Equal(start.Add(2*time.Second)) || !meta.UpdatedAt.Equal(meta.CreatedAt) {
+		t.Fatalf("got %+v, want the key created by the second set", meta)
+	}
+	c.mutex.RLock()
+	frequency := c.items["k"].frequency
+	c.mutex.RUnlock()
+	// The second set and the GetWithMeta above are its only uses
+	if frequency != 2 {
+		t.Fatalf("frequency %d, want the uses before expiry forgotten", frequency)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import "time"
+
+// Cacher is the set of operations shared by the cache implementations, so
+// callers can depend on it instead of a concrete cache and swap in NoopCache
+// or a test double
+type Cacher interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{}, expiration time.Duration)
+	Delete(key string) bool
+	Len() int
+	Close()
+}
+
+var (
+	_ Cacher = (*Cache)(nil)
+	_ Cacher = (*RingCache)(nil)
+	_ Cacher = NoopCache{}
+)
+
+// NoopCache is a Cacher that stores nothing, so every Get misses. It is
+// useful to turn caching off without changing the calling code.
+type NoopCache struct{}
+
+func (NoopCache) Get(string) (interface{}, bool)         { return nil, false }
+func (NoopCache) Set(string, interface{}, time.Duration) {}
+func (NoopCache) Delete(string) bool                     { return false }
+func (NoopCache) Len() int                               { return 0 }
+func (NoopCache) Close()                                 {}
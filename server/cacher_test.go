@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// memoize reads key through cache, computing it with compute on a miss
+func memoize(cache Cacher, key string, compute func() int) int {
+	if value, ok := cache.Get(key); ok {
+		return value.(int)
+	}
+	value := compute()
+	cache.Set(key, value, time.Minute)
+	return value
+}
+
+func TestCacherImplementations(t *testing.T) {
+	caches := map[string]Cacher{
+		"Cache":         NewCache(),
+		"sharded Cache": NewCacheWithOptions(Options{Capacity: 100, Shards: 4}),
+		"RingCache":     NewRingCache(Options{}, "a", "b"),
+	}
+	for name, cache := range caches {
+		calls := 0
+		compute := func() int { calls++; return 42 }
+		for i := 0; i < 3; i++ {
+			if got := memoize(cache, "answer", compute); got != 42 {
+				t.Errorf("%s: got %d", name, got)
+			}
+		}
+		if calls != 1 {
+			t.Errorf("%s: computed %d times, want 1", name, calls)
+		}
+		if cache.Len() != 1 || !cache.Delete("answer") || cache.Len() != 0 {
+			t.Errorf("%s: Len and Delete disagree", name)
+		}
+		cache.Close()
+	}
+
+	calls := 0
+	var noop NoopCache
+	for i := 0; i < 3; i++ {
+		memoize(noop, "answer", func() int { calls++; return 42 })
+	}
+	if calls != 3 || noop.Len() != 0 || noop.Delete("answer") {
+		t.Fatalf("NoopCache cached something: %d computations", calls)
+	}
+	noop.Close()
+}
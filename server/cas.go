@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"time"
+)
+
+// CompareAndSwap stores new under key only if the current live value is
+// deeply equal to old, reporting whether it did; a new value the cache refuses
+// to store, like one Set would drop, is not swapped in. A missing or expired key
+// matches an old value of nil, so CompareAndSwap(key, nil, v, ttl) creates it.
+func (c *Cache) CompareAndSwap(key string, old, new interface{}, expiration time.Duration) bool {
+	if c.parts != nil {
+		return c.part(key).CompareAndSwap(key, old, new, expiration)
+	}
+	c.lock()
+	defer c.unlock()
+	var current interface{}
+	if item, found := c.lookup(key); found {
+		current = item.load()
+	} else if old != nil {
+		return false
+	}
+	if !reflect.DeepEqual(current, old) {
+		return false
+	}
+	return c.set(key, new, expiration)
+}
+
+// SetIfAbsent stores the value only if key has no live value, reporting
+// whether it did. Concurrent callers for the same key see exactly one success.
+func (c *Cache) SetIfAbsent(key string, value interface{}, expiration time.Duration) bool {
+	if c.parts != nil {
+		return c.part(key).SetIfAbsent(key, value, expiration)
+	}
+	c.lock()
+	defer c.unlock()
+	if _, found := c.lookup(key); found {
+		return false
+	}
+	return c.set(key, value, expiration)
+}
+
+// SetIfPresent stores the value only if key already has a live value,
+// reporting whether it did. Missing or expired keys are not created.
+func (c *Cache) SetIfPresent(key string, value interface{}, expiration time.Duration) bool {
+	if c.parts != nil {
+		return c.part(key).SetIfPresent(key, value, expiration)
+	}
+	c.lock()
+	defer c.unlock()
+	if _, found := c.lookup(key); !found {
+		return false
+	}
+	return c.set(key, value, expiration)
+}
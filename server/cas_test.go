@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCompareAndSwap(t *testing.T) {
+	c := NewCacheWithOptions(Options{MaxValueBytes: 8})
+	defer c.Close()
+
+	if !c.CompareAndSwap("k", nil, map[string]int{"v": 1}, 0) {
+		t.Fatal("swap from nil did not create the key")
+	}
+	if c.CompareAndSwap("k", map[string]int{"v": 2}, "x", 0) {
+		t.Fatal("swap with a stale old value succeeded")
+	}
+	if !c.CompareAndSwap("k", map[string]int{"v": 1}, "x", 0) {
+		t.Fatal("swap with a deeply equal old value failed")
+	}
+	if c.CompareAndSwap("k", "x", strings.Repeat("y", 100), 0) {
+		t.Fatal("swap reported success for a value over MaxValueBytes")
+	}
+	if value, _ := c.Get("k"); value != "x" {
+		t.Fatalf("got %v, want x", value)
+	}
+}
+
+func TestCompareAndSwapConcurrent(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	c.Set("n", 0, 0)
+
+	for round := 0; round < 20; round++ {
+		var wins atomic.Int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if c.CompareAndSwap("n", round, round+1, 0) {
+					wins.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		if wins.Load() != 1 {
+			t.Fatalf("round %d: %d swaps won, want 1", round, wins.Load())
+		}
+	}
+	if value, _ := c.Get("n"); value != 20 {
+		t.Fatalf("got %v, want 20", value)
+	}
+}
+
+func TestSetIfAbsentConcurrent(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	var wins atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if c.SetIfAbsent("lock", i, 0) {
+				wins.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if wins.Load() != 1 {
+		t.Fatalf("%d callers stored the key, want 1", wins.Load())
+	}
+}
+
+func TestAddHandler(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+
+	if rec := serve(h, http.MethodPost, "/add", `{"key":"a","value":1}`); rec.Code != http.StatusCreated {
+		t.Fatalf("first add: status %d, want 201", rec.Code)
+	}
+	rec := serve(h, http.MethodPost, "/add", `{"key":"a","value":2}`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("repeated add: status %d, want 409", rec.Code)
+	}
+	if message, _ := decodeError(t, rec); message != "Key already exists" {
+		t.Fatalf("repeated add: message %q", message)
+	}
+	if value, _ := c.Get("a"); value != json.Number("1") {
+		t.Fatalf("a = %v, want the first value", value)
+	}
+}
+
+func TestSetIfPresent(t *testing.T) {
+	c := NewCacheWithOptions(Options{LazyExpiration: true})
+	defer c.Close()
+	h := NewServer(c)
+
+	if c.SetIfPresent("missing", 1, 0) || c.Has("missing") {
+		t.Fatal("SetIfPresent created a missing key")
+	}
+	c.Set("brief", 1, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if c.SetIfPresent("brief", 2, 0) {
+		t.Fatal("SetIfPresent replaced an expired key")
+	}
+
+	c.Set("a", 1, 0)
+	if !c.SetIfPresent("a", 2, time.Minute) {
+		t.Fatal("SetIfPresent refused a live key")
+	}
+	if ttl, _ := c.TTL("a"); ttl <= time.Minute-time.Second || ttl > time.Minute {
+		t.Fatalf("TTL %s, want the new expiration", ttl)
+	}
+
+	rec := serve(h, http.MethodPut, "/replace", `{"key":"a","value":3}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("/replace of a live key: status %d, want 201", rec.Code)
+	}
+	if value, _ := c.Get("a"); value != json.Number("3") {
+		t.Fatalf("a = %v after /replace", value)
+	}
+	rec = serve(h, http.MethodPut, "/replace", `{"key":"missing","value":3}`)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("/replace of a missing key: status %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,13 @@
+package main
+
+import "time"
+
+// Clock tells a cache the current time, which decides when keys expire
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the wall clock, the default for every cache
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
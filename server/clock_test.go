@@ -0,0 +1,69 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when told to, so that tests of
+// expiration can advance it rather than sleep
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// newFakeClock returns a fakeClock stopped at now
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+// Advance moves the clock forward by d
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.now = f.now.Add(d)
+}
+
+func TestTTLWithFakeClock(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := NewCacheWithOptions(Options{Clock: clock, LazyExpiration: true})
+	defer c.Close()
+
+	c.Set("short", "a", 5*time.Second)
+	c.Set("long", "b", time.Minute)
+	c.Set("forever", "c", 0)
+
+	clock.Advance(4 * time.Second)
+	if ttl, ok := c.TTL("short"); !ok || ttl != time.Second {
+		t.Fatalf("TTL after 4s: got %s, %t; want 1s", ttl, ok)
+	}
+	if _, ok := c.Get("short"); !ok {
+		t.Fatal("key expired 1s early")
+	}
+
+	clock.Advance(time.Second + time.Nanosecond)
+	if _, ok := c.Get("short"); ok {
+		t.Fatal("key outlived its 5s TTL")
+	}
+	if _, ok := c.TTL("short"); ok {
+		t.Fatal("TTL reported an expired key")
+	}
+
+	clock.Advance(time.Minute)
+	if n := c.evictExpiredItems(); n != 1 {
+		t.Fatalf("sweep removed %d keys, want 1", n)
+	}
+	if c.Len() != 1 {
+		t.Fatalf("got %d keys after the sweep, want 1", c.Len())
+	}
+	if ttl, ok := c.TTL("forever"); !ok || ttl != NoExpiration {
+		t.Fatalf("TTL of a permanent key: got %s, %t", ttl, ok)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Codec encodes and decodes request and response bodies for one media type.
+// Set request bodies are decoded into a struct with json tags, which codecs
+// should honor for field names.
+type Codec interface {
+	ContentType() string
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+// jsonCodec is the default codec. It decodes numbers as json.Number so
+// integers keep their exact value instead of becoming float64.
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string                     { return "application/json" }
+func (jsonCodec) Encode(w io.Writer, v interface{}) error { return json.NewEncoder(w).Encode(v) }
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+var (
+	codecsMutex sync.RWMutex
+	codecs      = map[string]Codec{"application/json": jsonCodec{}}
+)
+
+// RegisterCodec makes codec available to clients that name its content type
+// in the Accept or Content-Type header, replacing any codec for the same type
+func RegisterCodec(codec Codec) {
+	codecsMutex.Lock()
+	defer codecsMutex.Unlock()
+	codecs[codec.ContentType()] = codec
+}
+
+// lookupCodec returns the codec registered for mediaType
+func lookupCodec(mediaType string) (Codec, bool) {
+	codecsMutex.RLock()
+	defer codecsMutex.RUnlock()
+	codec, ok := codecs[mediaType]
+	return codec, ok
+}
+
+// requestCodec picks the codec for the body of r from its Content-Type. Bodies
+// without one, or with a type no codec is registered for such as the form
+// encoding curl sends by default, are read as JSON.
+func requestCodec(r *http.Request) Codec {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return jsonCodec{}
+	}
+	if codec, ok := lookupCodec(mediaType); ok {
+		return codec
+	}
+	return jsonCodec{}
+}
+
+// responseCodec picks the first registered codec listed in the Accept header
+// of r, JSON if there is none
+func responseCodec(r *http.Request) Codec {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		if codec, ok := lookupCodec(mediaType); ok {
+			return codec
+		}
+	}
+	return jsonCodec{}
+}
+
+// jsonEncoder returns an encoder for a JSON response to r, indenting the
+// output when the request asks for ?pretty=1
+func jsonEncoder(w io.Writer, r *http.Request) *json.Encoder {
+	encoder := json.NewEncoder(w)
+	if r.URL.Query().Get("pretty") == "1" {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder
+}
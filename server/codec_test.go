@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// guardedCodec is JSON behind the ")]}'" line some clients expect in front
+// of every body, standing in for any codec other than the default
+type guardedCodec struct{}
+
+const guardPrefix = ")]}'\n"
+
+func (guardedCodec) ContentType() string { return "application/x-guarded-json" }
+
+func (guardedCodec) Encode(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, guardPrefix); err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (guardedCodec) Decode(r io.Reader, v interface{}) error {
+	br := bufio.NewReader(r)
+	if line, err := br.ReadString('\n'); err != nil || line != guardPrefix {
+		return io.ErrUnexpectedEOF
+	}
+	return json.NewDecoder(br).Decode(v)
+}
+
+func TestRegisteredCodecRoundTrip(t *testing.T) {
+	RegisterCodec(guardedCodec{})
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+
+	req := httptest.NewRequest(http.MethodPost, "/set", strings.NewReader(guardPrefix+`{"key":"k","value":{"name":"ada","tags":["x"]}}`))
+	req.Header.Set("Content-Type", "application/x-guarded-json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("set: got status %d, body %q", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/get?key=k", nil)
+	req.Header.Set("Accept", "text/html, application/x-guarded-json")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Content-Type"); got != "application/x-guarded-json" {
+		t.Fatalf("get: got content type %q", got)
+	}
+	var value map[string]interface{}
+	if err := (guardedCodec{}).Decode(rec.Body, &value); err != nil {
+		t.Fatalf("get: body is not in the registered codec: %v", err)
+	}
+	if value["name"] != "ada" {
+		t.Fatalf("get: got %v", value)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/set", strings.NewReader(`{"key":"k","value":1}`))
+	req.Header.Set("Content-Type", "application/x-guarded-json")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("set without the guard: got status %d, want 400", rec.Code)
+	}
+}
+
+func TestUnknownContentTypeFallsBackToJSON(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+
+	req := httptest.NewRequest(http.MethodGet, "/get?key=k", nil)
+	req.Header.Set("Accept", "application/x-unknown")
+	c.Set("k", "v", 0)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Content-Type"); got != "application/json" || strings.TrimSpace(rec.Body.String()) != `"v"` {
+		t.Fatalf("got %q with body %q", got, rec.Body.String())
+	}
+}
+
+func TestLargeIntegersKeepPrecision(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+
+	// 2^53 + 1 is the first integer a float64 cannot hold
+	const big = "9007199254740993"
+	if rec := serve(h, http.MethodPost, "/set", `{"key":"id","value":`+big+`}`); rec.Code != http.StatusCreated {
+		t.Fatalf("/set: status %d", rec.Code)
+	}
+	if rec := serve(h, http.MethodGet, "/get?key=id", ""); rec.Body.String() != big+"\n" {
+		t.Fatalf("/get: got %q, want %s", rec.Body.String(), big)
+	}
+	if n, err := c.Increment("id", 1); err != nil || n != 9007199254740994 {
+		t.Fatalf("Increment: got %d, %v", n, err)
+	}
+
+	serve(h, http.MethodPost, "/mset", `[{"key":"batch","value":12345678901234567}]`)
+	if value, _ := c.Get("batch"); value != json.Number("12345678901234567") {
+		t.Fatalf("/mset stored %#v", value)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// DefaultCompressThreshold is the smallest value compressed when compression
+// is enabled without a threshold. Smaller values are not worth the overhead.
+const DefaultCompressThreshold = 1024
+
+// valueEncoding records how a CacheItem stores its value
+type valueEncoding uint8
+
+const (
+	plain      valueEncoding = iota // the value as given to Set
+	gzipString                      // a string, gzip-compressed into a []byte
+	gzipBytes                       // a []byte, gzip-compressed
+)
+
+// encode compresses large string and []byte values when compression is
+// enabled, returning the form to store and how it was encoded
+func (c *Cache) encode(value interface{}) (interface{}, valueEncoding) {
+	if c.compressAt == 0 {
+		return value, plain
+	}
+	var raw []byte
+	encoding := gzipBytes
+	switch v := value.(type) {
+	case string:
+		raw, encoding = []byte(v), gzipString
+	case []byte:
+		raw = v
+	default:
+		return value, plain
+	}
+	if len(raw) < c.compressAt {
+		return value, plain
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return value, plain
+	}
+	if err := zw.Close(); err != nil {
+		return value, plain
+	}
+	// Incompressible data is kept as is
+	if buf.Len() >= len(raw) {
+		return value, plain
+	}
+	return buf.Bytes(), encoding
+}
+
+// load returns the value as it was given to Set, decompressing it if needed
+func (item *CacheItem) load() interface{} {
+	if item.encoding == plain {
+		return item.value
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(item.value.([]byte)))
+	if err != nil {
+		return nil
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return nil
+	}
+	if item.encoding == gzipString {
+		return string(raw)
+	}
+	return raw
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	c := NewCacheWithOptions(Options{Compress: true, CompressThreshold: 64})
+	defer c.Close()
+
+	text := strings.Repeat("compressible ", 100)
+	blob := bytes.Repeat([]byte{1, 2, 3, 4}, 100)
+	c.Set("text", text, 0)
+	c.Set("blob", blob, 0)
+	c.Set("small", "tiny", 0)
+	c.Set("number", 12345, 0)
+
+	if got, _ := c.Get("text"); got != text {
+		t.Fatal("string did not survive compression")
+	}
+	if got, _ := c.Get("blob"); !bytes.Equal(got.([]byte), blob) {
+		t.Fatal("bytes did not survive compression")
+	}
+	if got, _ := c.Get("small"); got != "tiny" {
+		t.Fatalf("small string: got %v", got)
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	for _, tc := range []struct {
+		key      string
+		encoding valueEncoding
+		raw      int
+	}{
+		{"text", gzipString, len(text)},
+		{"blob", gzipBytes, len(blob)},
+	} {
+		item := c.items[tc.key]
+		if item.encoding != tc.encoding {
+			t.Errorf("%s: stored with encoding %d, want %d", tc.key, item.encoding, tc.encoding)
+		}
+		if stored := len(item.value.([]byte)); stored >= tc.raw {
+			t.Errorf("%s: stored %d bytes for %d", tc.key, stored, tc.raw)
+		}
+	}
+	if c.items["small"].encoding != plain || c.items["number"].encoding != plain {
+		t.Error("values under the threshold or of other types were compressed")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+)
+
+// config holds the server settings resolved from flags, the environment and defaults
+type config struct {
+	Addr       string
+	GRPCAddr   string
+	TextAddr   string
+	Capacity   int
+	Shards     int
+	DefaultTTL time.Duration
+	Lazy       bool
+	Snapshot   string
+	RateLimit  float64
+	RateBurst  int
+	CORS       bool
+	CORSOrigin string
+	APIKey     string
+	MaxBody    int64
+	MaxFlight  int
+	HTTPCache  bool
+	StatsEvery time.Duration
+	MaxNS      int
+	LogLevel   string
+}
+
+// String describes the configuration for the startup log
+func (cfg config) String() string {
+	return fmt.Sprintf("addr=%s grpc-addr=%s text-addr=%s capacity=%d shards=%d default-ttl=%s lazy-expiration=%t snapshot=%q rate-limit=%g rate-burst=%d cors=%t cors-origin=%q auth=%t max-body=%d max-in-flight=%d cache-control=%t stats-interval=%s max-namespaces=%d log-level=%q",
+		cfg.Addr, cfg.GRPCAddr, cfg.TextAddr, cfg.Capacity, cfg.Shards, cfg.DefaultTTL, cfg.Lazy, cfg.Snapshot, cfg.RateLimit, cfg.RateBurst, cfg.CORS, cfg.CORSOrigin, cfg.APIKey != "", cfg.MaxBody, cfg.MaxFlight, cfg.HTTPCache, cfg.StatsEvery, cfg.MaxNS, cfg.LogLevel)
+}
+
+// loadConfig resolves the configuration from args and getenv. A flag given on
+// the command line wins over its environment variable, which wins over the default.
+func loadConfig(args []string, getenv func(string) string) (config, error) {
+	var cfg config
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.StringVar(&cfg.Addr, "addr", ":8080", "listen address (env ADDR)")
+	fs.StringVar(&cfg.GRPCAddr, "grpc-addr", "", "gRPC listen address, empty to disable (env GRPC_ADDR)")
+	fs.StringVar(&cfg.TextAddr, "text-addr", "", "listen address for the line-based text protocol, empty to disable (env TEXT_ADDR)")
+	fs.IntVar(&cfg.Capacity, "capacity", DefaultCapacity, "maximum number of keys (env CAPACITY)")
+	fs.IntVar(&cfg.Shards, "shards", DefaultShards, "independently locked shards the keys of each namespace are split between, 1 for exact LRU order (env SHARDS)")
+	fs.DurationVar(&cfg.DefaultTTL, "default-ttl", 0, "expiration for sets that omit one, 0 for none (env DEFAULT_TTL)")
+	fs.BoolVar(&cfg.Lazy, "lazy-expiration", false, "only drop expired keys when they are accessed, without a background sweep (env LAZY_EXPIRATION)")
+	fs.StringVar(&cfg.Snapshot, "snapshot", "", "file to load the cache from on boot and save it to on shutdown (env SNAPSHOT)")
+	fs.Float64Var(&cfg.RateLimit, "rate-limit", 0, "requests per second per client on /get and /set, 0 for unlimited (env RATE_LIMIT)")
+	fs.IntVar(&cfg.RateBurst, "rate-burst", 10, "requests a client may burst above the rate limit (env RATE_BURST)")
+	fs.BoolVar(&cfg.CORS, "cors", false, "allow browsers on other origins to call the API (env CORS)")
+	fs.StringVar(&cfg.CORSOrigin, "cors-origin", "*", "origin allowed when CORS is on (env CORS_ORIGIN)")
+	fs.StringVar(&cfg.APIKey, "api-key", "", "key clients must send as X-API-Key or a bearer token, empty to disable (env API_KEY)")
+	fs.Int64Var(&cfg.MaxBody, "max-body", DefaultMaxBodyBytes, "largest request body in bytes, negative for unlimited (env MAX_BODY)")
+	fs.IntVar(&cfg.MaxFlight, "max-in-flight", 0, "requests handled at once before answering 503, 0 for unlimited (env MAX_IN_FLIGHT)")
+	fs.BoolVar(&cfg.HTTPCache, "cache-control", false, "send Cache-Control and Expires headers on /get for keys with a TTL (env CACHE_CONTROL)")
+	fs.DurationVar(&cfg.StatsEvery, "stats-interval", 0, "how often to sample the counters for /stats/history, 0 for never (env STATS_INTERVAL)")
+	fs.IntVar(&cfg.MaxNS, "max-namespaces", 0, "namespaces besides the default one that requests may create with ?ns=, 0 for unlimited (env MAX_NAMESPACES)")
+	fs.StringVar(&cfg.LogLevel, "log-level", "", "log cache activity at debug, info, warn or error and above to stderr, empty for none (env LOG_LEVEL)")
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	env := map[string]string{
+		"addr":            "ADDR",
+		"grpc-addr":       "GRPC_ADDR",
+		"text-addr":       "TEXT_ADDR",
+		"capacity":        "CAPACITY",
+		"shards":          "SHARDS",
+		"default-ttl":     "DEFAULT_TTL",
+		"lazy-expiration": "LAZY_EXPIRATION",
+		"snapshot":        "SNAPSHOT",
+		"rate-limit":      "RATE_LIMIT",
+		"rate-burst":      "RATE_BURST",
+		"cors":            "CORS",
+		"cors-origin":     "CORS_ORIGIN",
+		"api-key":         "API_KEY",
+		"max-body":        "MAX_BODY",
+		"max-in-flight":   "MAX_IN_FLIGHT",
+		"cache-control":   "CACHE_CONTROL",
+		"stats-interval":  "STATS_INTERVAL",
+		"max-namespaces":  "MAX_NAMESPACES",
+		"log-level":       "LOG_LEVEL",
+	}
+	for name, key := range env {
+		value := getenv(key)
+		if set[name] || value == "" {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return cfg, fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+
+	if cfg.Capacity <= 0 {
+		return cfg, fmt.Errorf("capacity must be positive, got %d", cfg.Capacity)
+	}
+	if cfg.Shards <= 0 {
+		return cfg, fmt.Errorf("shards must be positive, got %d", cfg.Shards)
+	}
+	if cfg.StatsEvery < 0 {
+		return cfg, fmt.Errorf("stats-interval must not be negative, got %s", cfg.StatsEvery)
+	}
+	if cfg.MaxFlight < 0 {
+		return cfg, fmt.Errorf("max-in-flight must not be negative, got %d", cfg.MaxFlight)
+	}
+	if cfg.MaxNS < 0 {
+		return cfg, fmt.Errorf("max-namespaces must not be negative, got %d", cfg.MaxNS)
+	}
+	if _, err := newLogger(io.Discard, cfg.LogLevel); err != nil {
+		return cfg, fmt.Errorf("invalid log-level: %w", err)
+	}
+	return cfg, nil
+}
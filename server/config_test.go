@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// envOf returns a getenv reading from vars
+func envOf(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	cfg, err := loadConfig(nil, envOf(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr != ":8080" || cfg.Capacity != DefaultCapacity || cfg.DefaultTTL != 0 || cfg.GRPCAddr != "" || cfg.Shards != DefaultShards {
+		t.Fatalf("defaults: got %+v", cfg)
+	}
+
+	env := envOf(map[string]string{"ADDR": ":9000", "CAPACITY": "50", "DEFAULT_TTL": "1m"})
+	cfg, err = loadConfig(nil, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr != ":9000" || cfg.Capacity != 50 || cfg.DefaultTTL != time.Minute {
+		t.Fatalf("environment: got %+v", cfg)
+	}
+
+	cfg, err = loadConfig([]string{"-capacity", "7", "-default-ttl", "5s"}, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr != ":9000" || cfg.Capacity != 7 || cfg.DefaultTTL != 5*time.Second {
+		t.Fatalf("flags over environment: got %+v", cfg)
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		env  map[string]string
+	}{
+		{[]string{"-capacity", "0"}, nil},
+		{[]string{"-shards", "0"}, nil},
+		{nil, map[string]string{"CAPACITY": "many"}},
+		{nil, map[string]string{"DEFAULT_TTL": "soon"}},
+		{[]string{"-max-in-flight", "-1"}, nil},
+	} {
+		if _, err := loadConfig(tc.args, envOf(tc.env)); err == nil {
+			t.Errorf("args %v and env %v: got no error", tc.args, tc.env)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// GetContext is Get that first checks ctx, returning ctx.Err() without
+// touching the cache if the context is already done
+func (c *Cache) GetContext(ctx context.Context, key string) (interface{}, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	value, ok := c.Get(key)
+	return value, ok, nil
+}
+
+// SetContext is Set that first checks ctx, returning ctx.Err() without
+// storing the value if the context is already done
+func (c *Cache) SetContext(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.Set(key, value, expiration)
+	return nil
+}
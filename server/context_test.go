@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestContextMethods(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	ctx := context.Background()
+	if err := c.SetContext(ctx, "a", 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok, err := c.GetContext(ctx, "a"); err != nil || !ok || value != 1 {
+		t.Fatalf("got %v, %t, %v", value, ok, err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := c.SetContext(cancelled, "b", 2, 0); !errors.Is(err, context.Canceled) {
+		t.Fatalf("SetContext with a cancelled context: got %v", err)
+	}
+	if _, ok := c.TTL("b"); ok {
+		t.Fatal("SetContext with a cancelled context stored the value")
+	}
+	if _, ok, err := c.GetContext(cancelled, "a"); !errors.Is(err, context.Canceled) || ok {
+		t.Fatalf("GetContext with a cancelled context: got %t, %v", ok, err)
+	}
+
+	expired, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer cancel()
+	if err := c.SetContext(expired, "b", 2, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("SetContext past its deadline: got %v", err)
+	}
+	if stats := c.Stats(); stats.Hits != 1 || stats.Misses != 0 {
+		t.Fatalf("cancelled calls touched the counters: %+v", stats)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import "net/http"
+
+// corsMethods are the methods browsers may use against the API
+const corsMethods = "GET, POST, PUT, DELETE, HEAD, OPTIONS"
+
+// corsHeaders are the request headers browsers may send to the API
+const corsHeaders = "Content-Type, Authorization, X-API-Key, X-Request-ID"
+
+// allowCORS wraps h so browsers on origin ("*" if empty) may call it, and
+// answers preflight OPTIONS requests without passing them on
+func allowCORS(h http.Handler, origin string) http.Handler {
+	if origin == "" {
+		origin = "*"
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if origin != "*" {
+			w.Header().Add("Vary", "Origin")
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", corsMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsHeaders)
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
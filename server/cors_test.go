@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServerWithOptions(c, ServerOptions{CORS: true, CORSOrigin: "https://app.example"})
+
+	req := httptest.NewRequest(http.MethodOptions, "/set", nil)
+	req.Header.Set("Origin", "https://app.example")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("preflight: got status %d, want 204", rec.Code)
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":  "https://app.example",
+		"Access-Control-Allow-Methods": corsMethods,
+		"Access-Control-Allow-Headers": corsHeaders,
+		"Vary":                         "Origin",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Errorf("preflight %s: got %q, want %q", header, got, want)
+		}
+	}
+
+	rec = serve(h, http.MethodGet, "/get?key=a", "")
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example" {
+		t.Fatalf("simple request: got status %d and origin %q", rec.Code, rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	rec = serve(NewServerWithOptions(c, ServerOptions{}), http.MethodGet, "/get?key=a", "")
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatal("CORS headers sent with CORS off")
+	}
+}
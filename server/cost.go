@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidCost is returned by SetWithCost for a cost below one unit
+var ErrInvalidCost = errors.New("cost must be at least 1")
+
+// SetWithCost stores the value like Set but counts it as cost units against
+// the MaxCost budget instead of the single unit every other write uses.
+// Least recently used items are evicted until the new one fits; an item
+// costing more than the whole budget is kept on its own. A cost below 1
+// stores nothing and returns ErrInvalidCost, and a value the cache refuses
+// returns the error TrySet would give for it.
+func (c *Cache) SetWithCost(key string, value interface{}, cost int64, expiration time.Duration) error {
+	if cost < 1 {
+		return fmt.Errorf("%w, got %d", ErrInvalidCost, cost)
+	}
+	if c.parts != nil {
+		return c.part(key).SetWithCost(key, value, cost, expiration)
+	}
+	c.lock()
+	defer c.unlock()
+	if err := c.admit(key, value); err != nil {
+		return err
+	}
+	c.set(key, value, expiration)
+	c.setCost(c.items[key], cost)
+	c.enforceCostBudget()
+	return nil
+}
+
+// setCost changes the cost of item and the cache total. The caller must hold the write lock.
+func (c *Cache) setCost(item *CacheItem, cost int64) {
+	c.cost += cost - item.cost
+	item.cost = cost
+}
+
+// enforceCostBudget evicts least recently used items until the total cost
+// fits within maxCost, never evicting the most recently used item.
+// The caller must hold the write lock.
+func (c *Cache) enforceCostBudget() {
+	if c.maxCost <= 0 {
+		return
+	}
+	for c.cost > c.maxCost && c.order.Len() > 1 {
+		c.evictOne(c.order.Front())
+	}
+}
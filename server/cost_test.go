@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSetWithCost(t *testing.T) {
+	c := NewCacheWithOptions(Options{MaxCost: 10})
+	defer c.Close()
+
+	c.Set("a", 1, 0)
+	c.SetWithCost("b", 2, 4, 0)
+	c.SetWithCost("c", 3, 5, 0)
+	if cost := c.Stats().Cost; cost != 10 {
+		t.Fatalf("cost %d, want 10", cost)
+	}
+	// Making room for d costs a and b, the least recently used
+	c.SetWithCost("d", 4, 3, 0)
+	if keys := c.Keys(); len(keys) != 2 || c.Has("a") || c.Has("b") {
+		t.Fatalf("left %v, want c and d", keys)
+	}
+	if cost := c.Stats().Cost; cost != 8 {
+		t.Fatalf("cost %d after eviction, want 8", cost)
+	}
+
+	// Overwriting with Set counts one unit again
+	c.Set("c", 3, 0)
+	if cost := c.Stats().Cost; cost != 4 {
+		t.Fatalf("cost %d after Set, want 4", cost)
+	}
+	c.Delete("c")
+	if cost := c.Stats().Cost; cost != 3 {
+		t.Fatalf("cost %d after Delete, want 3", cost)
+	}
+}
+
+func TestSetWithCostOverBudget(t *testing.T) {
+	c := NewCacheWithOptions(Options{MaxCost: 10})
+	defer c.Close()
+
+	c.Set("a", 1, 0)
+	c.SetWithCost("huge", 2, 50, 0)
+	if keys := c.Keys(); !reflect.DeepEqual(keys, []string{"huge"}) {
+		t.Fatalf("left %v, want the oversized item kept on its own", keys)
+	}
+	c.Set("b", 3, 0)
+	if keys := c.Keys(); !reflect.DeepEqual(keys, []string{"b"}) {
+		t.Fatalf("left %v, want the oversized item evicted for b", keys)
+	}
+}
+
+func TestSetWithCostRejectsInvalidCost(t *testing.T) {
+	store := newMapStore()
+	c := NewCacheWithOptions(Options{MaxCost: 10, Store: store, WriteThrough: true})
+	defer c.Close()
+
+	for _, cost := range []int64{0, -1} {
+		if err := c.SetWithCost("free", 1, cost, 0); !errors.Is(err, ErrInvalidCost) {
+			t.Errorf("cost %d: got %v, want ErrInvalidCost", cost, err)
+		}
+	}
+	if err := c.SetWithCost("", 1, 2, 0); !errors.Is(err, ErrEmptyKey) {
+		t.Fatalf("empty key: got %v, want ErrEmptyKey", err)
+	}
+	if c.Len() != 0 || c.Stats().Cost != 0 || len(store.values) != 0 {
+		t.Fatalf("refused writes left %v at cost %d", c.Keys(), c.Stats().Cost)
+	}
+
+	if err := c.SetWithCost("paid", 1, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if store.values["paid"] != 1 || c.Stats().Cost != 2 {
+		t.Fatalf("store holds %v at cost %d, want the value written through", store.values["paid"], c.Stats().Cost)
+	}
+}
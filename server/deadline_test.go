@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSetWithDeadline(t *testing.T) {
+	c := NewCacheWithOptions(Options{LazyExpiration: true})
+	defer c.Close()
+
+	c.SetWithDeadline("soon", 1, time.Now().Add(20*time.Millisecond))
+	if ttl, _ := c.TTL("soon"); ttl <= 0 || ttl > 20*time.Millisecond {
+		t.Fatalf("TTL %s, want at most 20ms", ttl)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if c.Has("soon") {
+		t.Fatal("key outlived its deadline")
+	}
+
+	c.Set("past", 1, 0)
+	c.SetWithDeadline("past", 2, time.Now().Add(-time.Second))
+	if c.Has("past") {
+		t.Fatal("a passed deadline kept the old value")
+	}
+	c.SetWithDeadline("forever", 3, time.Time{})
+	if ttl, _ := c.TTL("forever"); ttl != NoExpiration {
+		t.Fatalf("zero deadline: TTL %s", ttl)
+	}
+}
+
+func TestSetExpiresAt(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+
+	soon := time.Now().Add(90 * time.Second).Format(time.RFC3339)
+	if rec := serve(h, http.MethodPost, "/set", `{"key":"a","value":1,"expires_at":"`+soon+`"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("future expires_at: status %d", rec.Code)
+	}
+	// RFC 3339 without fractions rounds the deadline down to the second
+	if ttl, _ := c.TTL("a"); ttl <= 88*time.Second || ttl > 90*time.Second {
+		t.Fatalf("TTL %s, want about 90s", ttl)
+	}
+
+	past := time.Now().Add(-time.Second).Format(time.RFC3339)
+	tests := []struct{ body, message string }{
+		{`{"key":"b","value":1,"expires_at":"` + past + `"}`, "expires_at is in the past"},
+		{`{"key":"b","value":1,"expires_at":"tomorrow"}`, "Invalid expires_at timestamp"},
+		{`{"key":"b","value":1,"expiration":"1m","expires_at":"` + soon + `"}`, "Only one of expiration and expires_at may be given"},
+	}
+	for _, tt := range tests {
+		rec := serve(h, http.MethodPost, "/set", tt.body)
+		if message, _ := decodeError(t, rec); rec.Code != http.StatusBadRequest || message != tt.message {
+			t.Errorf("%s: status %d, message %q", tt.body, rec.Code, message)
+		}
+	}
+	if c.Has("b") {
+		t.Fatal("a rejected expires_at stored the key")
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefaultDumpLimit is the page size of /dump when ?limit= is omitted
+const DefaultDumpLimit = 50
+
+// maxDumpLimit caps the page size of /dump
+const maxDumpLimit = 1000
+
+// dumpRecord is one item on a /dump page
+type dumpRecord struct {
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value"`
+	ExpiresAt *time.Time  `json:"expires_at,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// Dump returns the live items at positions [offset, offset+limit) in key
+// order, along with the total number of live items. The lock is only held
+// to list the keys and to copy the requested page, not while sorting.
+func (c *Cache) Dump(offset, limit int) (records []dumpRecord, total int) {
+	keys := c.liveKeys()
+	sort.Strings(keys)
+	total = len(keys)
+	if offset >= total {
+		return []dumpRecord{}, total
+	}
+	keys = keys[offset:min(offset+limit, total)]
+	if c.parts == nil {
+		return c.dumpRecords(keys), total
+	}
+
+	found := make(map[string]dumpRecord, len(keys))
+	for i, group := range c.byPart(keys) {
+		for _, record := range c.parts[i].dumpRecords(group) {
+			found[record.Key] = record
+		}
+	}
+	records = make([]dumpRecord, 0, len(keys))
+	for _, key := range keys {
+		if record, ok := found[key]; ok {
+			records = append(records, record)
+		}
+	}
+	return records, total
+}
+
+// liveKeys lists the live keys in no particular order
+func (c *Cache) liveKeys() []string {
+	if c.parts != nil {
+		var keys []string
+		for _, part := range c.parts {
+			keys = append(keys, part.liveKeys()...)
+		}
+		return keys
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	now := c.clock.Now()
+	keys := make([]string, 0, len(c.items))
+	for key, item := range c.items {
+		if !item.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// dumpRecords copies the live items among keys, in the same order. It must
+// not be called on a sharded cache.
+func (c *Cache) dumpRecords(keys []string) []dumpRecord {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	now := c.clock.Now()
+	records := make([]dumpRecord, 0, len(keys))
+	for _, key := range keys {
+		// Keys removed since they were listed are skipped
+		item, found := c.items[key]
+		if !found || item.expired(now) {
+			continue
+		}
+		meta := item.meta()
+		record := dumpRecord{Key: key, Value: item.load(), CreatedAt: meta.CreatedAt, UpdatedAt: meta.UpdatedAt}
+		if !meta.ExpiresAt.IsZero() {
+			record.ExpiresAt = &meta.ExpiresAt
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// list a page of live items in key order, selected by ?offset= and ?limit=
+func (c *Cache) dumpHandler(w http.ResponseWriter, r *http.Request) {
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		writeError(w, r, "Invalid offset", http.StatusBadRequest)
+		return
+	}
+	limit, err := queryInt(r, "limit", DefaultDumpLimit)
+	if err != nil || limit <= 0 || limit > maxDumpLimit {
+		writeError(w, r, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	records, total := c.Dump(offset, limit)
+	w.Header().Set("Content-Type", "application/json")
+	jsonEncoder(w, r).Encode(struct {
+		Total  int          `json:"total"`
+		Offset int          `json:"offset"`
+		Limit  int          `json:"limit"`
+		Items  []dumpRecord `json:"items"`
+	}{total, offset, limit, records})
+}
+
+// queryInt parses the query parameter name as an integer, returning fallback if it is absent
+func queryInt(r *http.Request, name string, fallback int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(raw)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDumpPages(t *testing.T) {
+	c := NewCacheWithCapacity(200)
+	defer c.Close()
+	h := NewServer(c)
+	for i := 0; i < 120; i++ {
+		c.Set(fmt.Sprintf("key%03d", i), i, time.Hour)
+	}
+
+	var seen []string
+	for offset := 0; ; offset += 50 {
+		rec := serve(h, http.MethodGet, fmt.Sprintf("/dump?offset=%d", offset), "")
+		var page struct {
+			Total, Offset, Limit int
+			Items                []dumpRecord
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("offset %d: status %d, %v", offset, rec.Code, err)
+		}
+		if page.Total != 120 || page.Offset != offset || page.Limit != DefaultDumpLimit {
+			t.Fatalf("offset %d: got total %d, offset %d, limit %d", offset, page.Total, page.Offset, page.Limit)
+		}
+		if len(page.Items) == 0 {
+			break
+		}
+		for _, item := range page.Items {
+			if item.ExpiresAt == nil {
+				t.Fatalf("%s has no expires_at", item.Key)
+			}
+			seen = append(seen, item.Key)
+		}
+	}
+	if len(seen) != 120 {
+		t.Fatalf("pages held %d items, want 120", len(seen))
+	}
+	for i, key := range seen {
+		if want := fmt.Sprintf("key%03d", i); key != want {
+			t.Fatalf("item %d is %s, want %s", i, key, want)
+		}
+	}
+
+	for _, query := range []string{"offset=-1", "offset=x", "limit=0", "limit=1001"} {
+		if rec := serve(h, http.MethodGet, "/dump?"+query, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("/dump?%s: status %d, want 400", query, rec.Code)
+		}
+	}
+	if records, total := c.Dump(110, 20); len(records) != 10 || total != 120 {
+		t.Fatalf("last page: %d records of %d", len(records), total)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// evictionEvent describes one evicted key to subscribers of /events
+type evictionEvent struct {
+	Key    string      `json:"key"`
+	Value  interface{} `json:"value"`
+	Reason string      `json:"reason"` // "expired" or "capacity"
+}
+
+// eventBuffer is how many events a subscriber may fall behind before new ones are dropped for it
+const eventBuffer = 64
+
+// evictionFeed fans eviction events out to any number of subscribers
+type evictionFeed struct {
+	mutex       sync.RWMutex
+	subscribers map[chan evictionEvent]struct{}
+}
+
+// subscribe returns a channel receiving every eviction from now on and a
+// function that ends the subscription
+func (f *evictionFeed) subscribe() (<-chan evictionEvent, func()) {
+	events := make(chan evictionEvent, eventBuffer)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.subscribers == nil {
+		f.subscribers = make(map[chan evictionEvent]struct{})
+	}
+	f.subscribers[events] = struct{}{}
+	return events, func() {
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		delete(f.subscribers, events)
+	}
+}
+
+// active reports whether anyone is subscribed
+func (f *evictionFeed) active() bool {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return len(f.subscribers) > 0
+}
+
+// publish sends event to every subscriber without waiting on slow ones
+func (f *evictionFeed) publish(event evictionEvent) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	for events := range f.subscribers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// publishEvicted reports the items evicted under the last write lock to the
+// feed. The lock must already be released.
+func (c *Cache) publishEvicted(evicted []evictedItem) {
+	if len(evicted) == 0 || !c.feed.active() {
+		return
+	}
+	now := c.clock.Now()
+	for _, e := range evicted {
+		reason := "capacity"
+		if e.item.expired(now) {
+			reason = "expired"
+		}
+		c.feed.publish(evictionEvent{Key: e.key, Value: e.item.load(), Reason: reason})
+	}
+}
+
+// stream every eviction to the client as server-sent events until it disconnects
+func (c *Cache) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	events, unsubscribe := c.feed.subscribe()
+	defer unsubscribe()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: evict\ndata: %s\n\n", data)
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventsStreamsEvictions(t *testing.T) {
+	c := NewCacheWithOptions(Options{Capacity: 1, SweepInterval: time.Hour})
+	defer c.Close()
+	srv := httptest.NewServer(NewServer(c))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type %q", ct)
+	}
+	// The headers are flushed after subscribing, so the evictions below are seen
+	c.Set("a", "first", 10*time.Millisecond)
+	c.Set("b", "second", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	c.Get("b")
+
+	lines := bufio.NewScanner(resp.Body)
+	var got []evictionEvent
+	for len(got) < 2 && lines.Scan() {
+		data, found := strings.CutPrefix(lines.Text(), "data: ")
+		if !found {
+			continue
+		}
+		var event evictionEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, event)
+	}
+	want := []evictionEvent{
+		{Key: "a", Value: "first", Reason: "capacity"},
+		{Key: "b", Value: "second", Reason: "expired"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events: %v", len(got), lines.Err())
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
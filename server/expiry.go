@@ -0,0 +1,54 @@
+package main
+
+import "container/heap"
+
+// expiryHeap is a min-heap of the items that can expire, soonest first, so the
+// sweep only visits items that are actually due. Items that never expire are
+// left out of it.
+type expiryHeap []*CacheItem
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expiration < h[j].expiration }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].heapIndex = i
+	h[j].heapIndex = j
+}
+
+func (h *expiryHeap) Push(x any) {
+	item := x.(*CacheItem)
+	item.heapIndex = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.heapIndex = -1
+	*h = old[:n-1]
+	return item
+}
+
+// setExpiration changes the deadline of item and keeps the expiry heap in
+// order. The caller must hold the write lock.
+func (c *Cache) setExpiration(item *CacheItem, expiration int64) {
+	item.expiration = expiration
+	switch {
+	case item.heapIndex >= 0 && expiration == 0:
+		heap.Remove(&c.expiries, item.heapIndex)
+	case item.heapIndex >= 0:
+		heap.Fix(&c.expiries, item.heapIndex)
+	case expiration != 0:
+		heap.Push(&c.expiries, item)
+	}
+}
+
+// unschedule drops item from the expiry heap. The caller must hold the write lock.
+func (c *Cache) unschedule(item *CacheItem) {
+	if item.heapIndex >= 0 {
+		heap.Remove(&c.expiries, item.heapIndex)
+	}
+}
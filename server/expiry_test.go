@@ -0,0 +1,107 @@
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// checkHeap fails unless every item in the expiry heap knows its index and no
+// item is ordered before its parent
+func checkHeap(t *testing.T, c *Cache) {
+	t.Helper()
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	for i, item := range c.expiries {
+		if item.heapIndex != i {
+			t.Fatalf("item at %d records index %d", i, item.heapIndex)
+		}
+		if parent := (i - 1) / 2; i > 0 && c.expiries.Less(i, parent) {
+			t.Fatalf("item at %d expires before its parent", i)
+		}
+	}
+}
+
+func TestExpiryHeap(t *testing.T) {
+	c := NewCacheWithOptions(Options{Capacity: 1000, SweepInterval: time.Hour})
+	defer c.Close()
+
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		c.Set(strconv.Itoa(i), i, time.Duration(1+random.Intn(100))*time.Millisecond)
+	}
+	for i := 0; i < 1000; i += 7 {
+		c.UpdateTTL(strconv.Itoa(i), time.Duration(random.Intn(200))*time.Millisecond)
+	}
+	for i := 0; i < 1000; i += 11 {
+		c.Delete(strconv.Itoa(i))
+	}
+	checkHeap(t, c)
+
+	time.Sleep(50 * time.Millisecond)
+	// Anything already expired at now must be gone after the sweep that follows
+	now := time.Now()
+	c.evictExpiredItems()
+	checkHeap(t, c)
+	c.mutex.RLock()
+	for key, item := range c.items {
+		if item.expired(now) {
+			t.Errorf("expired key %s survived the sweep", key)
+		}
+		if item.expiration == 0 && item.heapIndex != -1 {
+			t.Errorf("permanent key %s is in the heap", key)
+		}
+	}
+	if len(c.expiries) > len(c.items) {
+		t.Fatalf("heap holds %d items for %d keys", len(c.expiries), len(c.items))
+	}
+	c.mutex.RUnlock()
+}
+
+func BenchmarkSweep(b *testing.B) {
+	c := NewCacheWithOptions(Options{Capacity: 100000, SweepInterval: time.Hour})
+	defer c.Close()
+	for i := 0; i < 100000; i++ {
+		c.Set(strconv.Itoa(i), i, time.Hour)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Nothing is due, which a full scan would still visit every item to find out
+		c.evictExpiredItems()
+	}
+}
+
+func TestSweepBatches(t *testing.T) {
+	c := NewCacheWithOptions(Options{Capacity: 200, LazyExpiration: true, SweepBatch: 10, LockMetrics: true})
+	defer c.Close()
+	for i := 0; i < 95; i++ {
+		c.Set(strconv.Itoa(i), i, 10*time.Millisecond)
+	}
+	c.Set("forever", 1, 0)
+	time.Sleep(20 * time.Millisecond)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c.locks.hold)
+	holds := func() uint64 {
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return families[0].GetMetric()[0].GetHistogram().GetSampleCount()
+	}
+
+	before := holds()
+	if evicted := c.evictExpiredItems(); evicted != 95 {
+		t.Fatalf("evicted %d items, want 95", evicted)
+	}
+	// Nine full batches and a last one of five, each under its own lock hold
+	if got := holds() - before; got != 10 {
+		t.Fatalf("sweep took the lock %d times, want 10", got)
+	}
+	if c.Len() != 1 || len(c.expiries) != 0 {
+		t.Fatalf("%d items and %d scheduled expiries left", c.Len(), len(c.expiries))
+	}
+}
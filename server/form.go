@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+)
+
+// formContentType is the body encoding of HTML forms and of curl -d
+const formContentType = "application/x-www-form-urlencoded"
+
+// formSetValues returns the fields of a set request given as URL query
+// parameters (for GET) or as a form-encoded body. It returns false for
+// requests that carry a JSON body instead, restoring any body it read.
+func formSetValues(r *http.Request) (url.Values, bool, error) {
+	if r.Method == http.MethodGet {
+		return r.URL.Query(), true, nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != formContentType {
+		return nil, false, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	// curl -d sends JSON bodies with the form content type
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '{' {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return nil, false, nil
+	}
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, false, err
+	}
+	return values, true, nil
+}
+
+// decodeFormSet builds a set request from the key, value and ttl fields, the
+// key argument taking precedence over the field when it is not empty
+func (c *Cache) decodeFormSet(w http.ResponseWriter, r *http.Request, values url.Values, key string) (setRequest, bool) {
+	if key == "" {
+		key = values.Get("key")
+	}
+	if err := c.CheckKey(key); err != nil {
+		writeError(w, r, err.Error(), http.StatusBadRequest)
+		return setRequest{}, false
+	}
+	if !values.Has("value") {
+		writeError(w, r, "Value is required", http.StatusBadRequest)
+		return setRequest{}, false
+	}
+	expiration, err := c.parseExpiration(values.Get("ttl"))
+	if err != nil {
+		writeError(w, r, "Invalid ttl duration", http.StatusBadRequest)
+		return setRequest{}, false
+	}
+	return setRequest{key: key, value: values.Get("value"), expiration: expiration}, true
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetFromQueryAndForm(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+
+	if rec := serve(h, http.MethodGet, "/set?key=q&value=hello%20world&ttl=30s", ""); rec.Code != http.StatusCreated {
+		t.Fatalf("GET /set: status %d, body %q", rec.Code, rec.Body.String())
+	}
+	if value, _ := c.Get("q"); value != "hello world" {
+		t.Fatalf("q = %#v", value)
+	}
+	if ttl, _ := c.TTL("q"); ttl <= 0 || ttl > 30*time.Second {
+		t.Fatalf("ttl ignored: %s", ttl)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/set", strings.NewReader("key=f&value=42"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if value, _ := c.Get("f"); rec.Code != http.StatusCreated || value != "42" {
+		t.Fatalf("form /set: status %d, stored %#v", rec.Code, value)
+	}
+
+	// curl -d sends JSON with the form content type
+	req = httptest.NewRequest(http.MethodPost, "/set", strings.NewReader(`{"key":"j","value":1}`))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated || !c.Has("j") {
+		t.Fatalf("JSON sent as a form: status %d", rec.Code)
+	}
+
+	tests := []struct{ target, message string }{
+		{"/set?value=1", "key is empty"},
+		{"/set?key=k", "Value is required"},
+		{"/set?key=k&value=1&ttl=soon", "Invalid ttl duration"},
+	}
+	for _, tt := range tests {
+		rec := serve(h, http.MethodGet, tt.target, "")
+		if message, _ := decodeError(t, rec); rec.Code != http.StatusBadRequest || message != tt.message {
+			t.Errorf("%s: status %d, message %q", tt.target, rec.Code, message)
+		}
+	}
+}
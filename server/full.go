@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrCacheFull is returned by TrySet when the cache is at capacity with
+// RejectWhenFull set and no expired key can be reclaimed
+var ErrCacheFull = errors.New("cache is full")
+
+// TrySet stores the value like Set, but reports why it could not instead of
+// dropping it: the CheckKey error for an invalid key, the CheckValueSize error
+// for an oversized value, or ErrCacheFull for a new key when the cache rejects
+// sets at capacity. Replacing a key that is already present cannot be refused
+// for lack of room.
+func (c *Cache) TrySet(key string, value interface{}, expiration time.Duration) error {
+	if c.parts != nil {
+		return c.part(key).TrySet(key, value, expiration)
+	}
+	c.lock()
+	if err := c.admit(key, value); err != nil {
+		c.unlock()
+		return err
+	}
+	c.set(key, value, expiration)
+	c.unlock()
+	c.logger.Debug("cache set", "key", key, "expiration", expiration)
+	return nil
+}
+
+// TrySetWithDeadline stores the value like SetWithDeadline, but reports why
+// it could not as TrySet does
+func (c *Cache) TrySetWithDeadline(key string, value interface{}, deadline time.Time) error {
+	if c.parts != nil {
+		return c.part(key).TrySetWithDeadline(key, value, deadline)
+	}
+	c.lock()
+	defer c.unlock()
+	if err := c.admit(key, value); err != nil {
+		return err
+	}
+	c.setWithDeadline(key, value, deadline)
+	return nil
+}
+
+// TrySetMany stores every item like SetMany, or none of them if any would be
+// refused, returning the error TrySet would give for the first such item.
+// A sharded cache holds the lock of every shard meanwhile.
+func (c *Cache) TrySetMany(items map[string]CacheItem) error {
+	c.lockAll()
+	defer c.unlockAll()
+	added := make(map[*Cache]int)
+	for key, item := range items {
+		if err := c.CheckKey(key); err != nil {
+			return err
+		}
+		if err := c.CheckValueSize(item.value); err != nil {
+			return err
+		}
+		if owner := c.owner(key); owner.items[key] == nil {
+			added[owner]++
+		}
+	}
+	for owner, n := range added {
+		if !owner.hasRoomFor(n) {
+			return ErrCacheFull
+		}
+	}
+	for key, item := range items {
+		c.owner(key).put(key, item.value, item.expiration)
+	}
+	return nil
+}
+
+// admit returns the error that would make put refuse to store value under key,
+// or nil if it would be stored. The caller must hold the write lock.
+func (c *Cache) admit(key string, value interface{}) error {
+	if err := c.CheckKey(key); err != nil {
+		return err
+	}
+	if err := c.CheckValueSize(value); err != nil {
+		return err
+	}
+	if _, found := c.items[key]; !found && !c.hasRoom() {
+		return ErrCacheFull
+	}
+	return nil
+}
+
+// hasRoom reports whether a new key can be added. It is always true unless
+// RejectWhenFull is set, in which case a full cache first reclaims its expired
+// keys. The caller must hold the write lock.
+func (c *Cache) hasRoom() bool {
+	return c.hasRoomFor(1)
+}
+
+// hasRoomFor is hasRoom for n new keys at once. The caller must hold the write lock.
+func (c *Cache) hasRoomFor(n int) bool {
+	if !c.rejectFull || len(c.items)+n <= c.capacity {
+		return true
+	}
+	c.evictDue(c.clock.Now(), 0)
+	return len(c.items)+n <= c.capacity
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTrySetRejectWhenFull(t *testing.T) {
+	c := NewCacheWithOptions(Options{Capacity: 2, RejectWhenFull: true, LazyExpiration: true})
+	defer c.Close()
+
+	if err := c.TrySet("a", 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.TrySet("brief", 2, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.TrySet("c", 3, 0); !errors.Is(err, ErrCacheFull) {
+		t.Fatalf("new key in a full cache: got %v, want ErrCacheFull", err)
+	}
+	c.Set("c", 3, 0)
+	if c.Has("c") {
+		t.Fatal("Set added a key to a full cache")
+	}
+	if err := c.TrySet("a", 10, 0); err != nil {
+		t.Fatalf("replacing a present key was refused: %v", err)
+	}
+	if !c.Has("brief") || c.Stats().Evictions != 0 {
+		t.Fatal("a full cache evicted a live key")
+	}
+
+	// Expired keys are reclaimed to make room
+	time.Sleep(20 * time.Millisecond)
+	if err := c.TrySet("c", 3, 0); err != nil {
+		t.Fatalf("after brief expired: %v", err)
+	}
+	if err := c.TrySet("", 1, 0); !errors.Is(err, ErrEmptyKey) {
+		t.Fatalf("empty key: got %v, want ErrEmptyKey", err)
+	}
+	if err := c.TrySetMany(map[string]CacheItem{"a": {value: 1}, "d": {value: 4}}); !errors.Is(err, ErrCacheFull) {
+		t.Fatalf("TrySetMany: got %v, want ErrCacheFull", err)
+	}
+	if value, _ := c.Get("a"); value != 10 {
+		t.Fatalf("refused batch changed a to %v", value)
+	}
+}
+
+func TestIncrementAndGetOrSetWhenRefused(t *testing.T) {
+	c := NewCacheWithOptions(Options{Capacity: 1, RejectWhenFull: true, MaxKeyLength: 8})
+	defer c.Close()
+	c.Set("a", 1, 0)
+
+	if _, err := c.Increment("new", 1); !errors.Is(err, ErrCacheFull) {
+		t.Fatalf("Increment of a new key: got %v, want ErrCacheFull", err)
+	}
+	if n, err := c.Increment("a", 1); err != nil || n != 2 {
+		t.Fatalf("Increment of a present key: got %d, %v", n, err)
+	}
+	if actual, loaded, err := c.GetOrSet("new", 1, 0); !errors.Is(err, ErrCacheFull) || actual != nil || loaded {
+		t.Fatalf("GetOrSet of a new key: got %v, %t, %v; want ErrCacheFull", actual, loaded, err)
+	}
+	if actual, loaded, err := c.GetOrSet("a", 5, 0); err != nil || !loaded || actual != int64(2) {
+		t.Fatalf("GetOrSet of a present key: got %v, %t, %v", actual, loaded, err)
+	}
+	if c.Has("new") {
+		t.Fatal("a refused key was stored")
+	}
+
+	c.Delete("a")
+	if _, err := c.Increment("much too long", 1); !errors.Is(err, ErrKeyTooLong) {
+		t.Fatalf("Increment of a long key: got %v, want ErrKeyTooLong", err)
+	}
+	if _, _, err := c.GetOrSet("", 1, 0); !errors.Is(err, ErrEmptyKey) {
+		t.Fatalf("GetOrSet of an empty key: got %v, want ErrEmptyKey", err)
+	}
+	if c.Len() != 0 {
+		t.Fatalf("refused keys left %v", c.Keys())
+	}
+}
+
+func TestFullCacheAnswers507(t *testing.T) {
+	c := NewCacheWithOptions(Options{Capacity: 1, RejectWhenFull: true})
+	defer c.Close()
+	h := NewServer(c)
+
+	if rec := serve(h, http.MethodPost, "/set", `{"key":"a","value":1}`); rec.Code != http.StatusCreated {
+		t.Fatalf("first set: status %d", rec.Code)
+	}
+	soon := time.Now().Add(time.Hour).Format(time.RFC3339)
+	for _, body := range []string{
+		`{"key":"b","value":1}`,
+		`{"key":"b","value":1,"expires_at":"` + soon + `"}`,
+	} {
+		rec := serve(h, http.MethodPost, "/set", body)
+		if message, _ := decodeError(t, rec); rec.Code != http.StatusInsufficientStorage || message != "Cache is full" {
+			t.Errorf("%s: status %d, message %q", body, rec.Code, message)
+		}
+	}
+	if rec := serve(h, http.MethodPost, "/mset", `[{"key":"a","value":2},{"key":"c","value":1}]`); rec.Code != http.StatusInsufficientStorage {
+		t.Errorf("/mset: status %d, want 507", rec.Code)
+	}
+	if rec := serve(h, http.MethodPost, "/add", `{"key":"b","value":3}`); rec.Code != http.StatusInsufficientStorage {
+		t.Errorf("/add: status %d, want 507", rec.Code)
+	}
+	if rec := serve(h, http.MethodPost, "/incr?key=b", ""); rec.Code != http.StatusInsufficientStorage {
+		t.Errorf("/incr: status %d, want 507", rec.Code)
+	}
+	if value, _ := c.Get("a"); value != json.Number("1") {
+		t.Fatalf("a = %v after refused writes", value)
+	}
+	if rec := serve(h, http.MethodPost, "/set", `{"key":"a","value":3}`); rec.Code != http.StatusCreated {
+		t.Fatalf("replacing a: status %d", rec.Code)
+	}
+	if reply := textCommand(c, []string{"SET", "z", "1"}); reply != "ERR cache is full" {
+		t.Fatalf("text SET: got %q", reply)
+	}
+}
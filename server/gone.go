@@ -0,0 +1,96 @@
+package main
+
+import (
+	"container/list"
+	"errors"
+)
+
+// ErrNotFound is returned by Fetch for a key that is not in the cache
+var ErrNotFound = errors.New("key not found")
+
+// ErrExpired is returned by Fetch for a key that was stored but has since expired
+var ErrExpired = errors.New("key expired")
+
+// tombstones remembers keys removed by expiration so reads can tell them apart
+// from keys that were never set. It holds at most as many keys as the cache,
+// forgetting the oldest first.
+type tombstones struct {
+	keys  map[string]*list.Element
+	order *list.List // keys, most recently expired at the front
+}
+
+// bury records that key expired, forgetting the oldest key beyond limit
+func (t *tombstones) bury(key string, limit int) {
+	if t.keys == nil {
+		t.keys = make(map[string]*list.Element)
+		t.order = list.New()
+	}
+	if e, found := t.keys[key]; found {
+		t.order.MoveToFront(e)
+		return
+	}
+	t.keys[key] = t.order.PushFront(key)
+	if t.order.Len() > limit {
+		delete(t.keys, t.order.Remove(t.order.Back()).(string))
+	}
+}
+
+// forget drops key, for example once it is stored again
+func (t *tombstones) forget(key string) {
+	if e, found := t.keys[key]; found {
+		t.order.Remove(e)
+		delete(t.keys, key)
+	}
+}
+
+// has reports whether key expired recently
+func (t *tombstones) has(key string) bool {
+	_, found := t.keys[key]
+	return found
+}
+
+// Fetch retrieves the value for key like Get, but reports why a read missed:
+// ErrCachedMiss if SetMiss recorded key as absent, ErrExpired if key expired
+// recently and ErrNotFound otherwise. The last two fall through to the backing
+// store first, if there is one. Only as many
+// expired keys as the cache capacity are remembered, so long expired keys
+// eventually report ErrNotFound.
+func (c *Cache) Fetch(key string) (interface{}, error) {
+	value, _, err := c.fetch(key)
+	return value, err
+}
+
+// fetch is Fetch that also returns the Unix nanosecond deadline of the value, 0 if it never expires
+func (c *Cache) fetch(key string) (interface{}, int64, error) {
+	if c.parts != nil {
+		return c.part(key).fetch(key)
+	}
+	value, expiration, err := c.fetchCached(key)
+	// A key recorded with SetMiss is known to be absent from the store as well
+	if c.store != nil && (errors.Is(err, ErrNotFound) || errors.Is(err, ErrExpired)) {
+		if value, expiration, ok := c.readThrough(key); ok {
+			return value, expiration, nil
+		}
+	}
+	return value, expiration, err
+}
+
+// fetchCached is fetch without falling through to the backing store
+func (c *Cache) fetchCached(key string) (interface{}, int64, error) {
+	c.lock()
+	defer c.unlock()
+	item, found := c.lookup(key)
+	if !found {
+		c.misses.Add(1)
+		if c.cachedMiss(key, c.clock.Now()) {
+			return nil, 0, ErrCachedMiss
+		}
+		if c.expired.has(key) {
+			return nil, 0, ErrExpired
+		}
+		return nil, 0, ErrNotFound
+	}
+	c.touch(item)
+	c.hits.Add(1)
+	return item.load(), item.expiration, nil
+}
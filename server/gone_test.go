@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFetchExpiredOrUnknown(t *testing.T) {
+	c := NewCacheWithOptions(Options{Capacity: 2, SweepInterval: time.Hour})
+	defer c.Close()
+	h := NewServer(c)
+
+	c.Set("a", 1, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if _, err := c.Fetch("a"); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expired key: got %v, want ErrExpired", err)
+	}
+	if rec := serve(h, http.MethodGet, "/get?key=a", ""); rec.Code != http.StatusGone {
+		t.Fatalf("/get of an expired key: status %d, want 410", rec.Code)
+	}
+	if rec := serve(h, http.MethodGet, "/get?key=never", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("/get of an unknown key: status %d, want 404", rec.Code)
+	}
+
+	c.Set("a", 2, 0)
+	if value, err := c.Fetch("a"); err != nil || value != 2 {
+		t.Fatalf("stored again: got %v, %v", value, err)
+	}
+	c.Delete("a")
+	if _, err := c.Fetch("a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("deleted key: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestTombstonesBounded(t *testing.T) {
+	c := NewCacheWithOptions(Options{Capacity: 2, SweepInterval: time.Hour})
+	defer c.Close()
+
+	for _, key := range []string{"x", "y", "z"} {
+		c.Set(key, 1, 10*time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+		c.Fetch(key)
+	}
+	if _, err := c.Fetch("x"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("oldest tombstone: got %v, want ErrNotFound once forgotten", err)
+	}
+	for _, key := range []string{"y", "z"} {
+		if _, err := c.Fetch(key); !errors.Is(err, ErrExpired) {
+			t.Fatalf("%s: got %v, want ErrExpired", key, err)
+		}
+	}
+}
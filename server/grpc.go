@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative cache.proto
+
+// grpcService implements the Cache service of cache.proto on top of a *Cache
+type grpcService struct {
+	UnimplementedCacheServer
+	cache *Cache
+}
+
+func (s grpcService) Get(_ context.Context, req *GetRequest) (*GetResponse, error) {
+	value, ok := s.cache.Get(req.Key)
+	if !ok {
+		return &GetResponse{}, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &GetResponse{Value: data, Found: true}, nil
+}
+
+func (s grpcService) Set(_ context.Context, req *SetRequest) (*SetResponse, error) {
+	if err := s.cache.CheckKey(req.Key); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	var value interface{}
+	if len(req.Value) > 0 {
+		if err := (jsonCodec{}).Decode(bytes.NewReader(req.Value), &value); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	err := s.cache.TrySet(req.Key, value, time.Duration(req.TtlMs)*time.Millisecond)
+	switch {
+	case errors.Is(err, ErrCacheFull):
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &SetResponse{}, nil
+}
+
+func (s grpcService) Delete(_ context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	return &DeleteResponse{Deleted: s.cache.Delete(req.Key)}, nil
+}
+
+func (s grpcService) TTL(_ context.Context, req *TTLRequest) (*TTLResponse, error) {
+	ttl, ok := s.cache.TTL(req.Key)
+	if !ok {
+		return &TTLResponse{}, nil
+	}
+	if ttl == NoExpiration {
+		return &TTLResponse{TtlMs: -1, Found: true}, nil
+	}
+	return &TTLResponse{TtlMs: ttl.Milliseconds(), Found: true}, nil
+}
+
+// NewGRPCServer creates a gRPC server exposing cache through the Cache service of cache.proto
+func NewGRPCServer(cache *Cache) *grpc.Server {
+	server := grpc.NewServer()
+	RegisterCacheServer(server, grpcService{cache: cache})
+	return server
+}
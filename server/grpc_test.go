@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newGRPCClient serves cache over an in-memory listener and returns a client for it
+func newGRPCClient(t *testing.T, cache *Cache) CacheClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 16)
+	server := NewGRPCServer(cache)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewCacheClient(conn)
+}
+
+func TestGRPCServer(t *testing.T) {
+	cache := NewCache()
+	defer cache.Close()
+	client := newGRPCClient(t, cache)
+	ctx := context.Background()
+
+	if _, err := client.Set(ctx, &SetRequest{Key: "k", Value: []byte(`{"a":1}`), TtlMs: 60000}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := client.Get(ctx, &GetRequest{Key: "k"})
+	if err != nil || !got.Found || string(got.Value) != `{"a":1}` {
+		t.Fatalf("Get: got %v, %v", got, err)
+	}
+	ttl, err := client.TTL(ctx, &TTLRequest{Key: "k"})
+	if err != nil || !ttl.Found || ttl.TtlMs <= 0 || ttl.TtlMs > 60000 {
+		t.Fatalf("TTL: got %v, %v; want within 60000ms", ttl, err)
+	}
+	deleted, err := client.Delete(ctx, &DeleteRequest{Key: "k"})
+	if err != nil || !deleted.Deleted {
+		t.Fatalf("Delete: got %v, %v", deleted, err)
+	}
+	got, err = client.Get(ctx, &GetRequest{Key: "k"})
+	if err != nil || got.Found || got.Value != nil {
+		t.Fatalf("Get after Delete: got %v, %v", got, err)
+	}
+
+	cache.Set("forever", 1, 0)
+	if ttl, _ := client.TTL(ctx, &TTLRequest{Key: "forever"}); !ttl.Found || ttl.TtlMs != -1 {
+		t.Fatalf("TTL of a key without expiration: got %v, want -1", ttl)
+	}
+}
+
+func TestGRPCSetErrors(t *testing.T) {
+	cache := NewCacheWithOptions(Options{Capacity: 1, RejectWhenFull: true})
+	defer cache.Close()
+	client := newGRPCClient(t, cache)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		req  *SetRequest
+		want codes.Code
+	}{
+		{&SetRequest{Key: "", Value: []byte(`1`)}, codes.InvalidArgument},
+		{&SetRequest{Key: "a", Value: []byte(`{`)}, codes.InvalidArgument},
+		{&SetRequest{Key: "a", Value: []byte(`1`)}, codes.OK},
+		{&SetRequest{Key: "b", Value: []byte(`1`)}, codes.ResourceExhausted},
+	} {
+		if _, err := client.Set(ctx, tc.req); status.Code(err) != tc.want {
+			t.Errorf("Set(%v): got %v, want %s", tc.req, err, tc.want)
+		}
+	}
+}
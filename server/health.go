@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// health serves the liveness and readiness probes
+type health struct {
+	cache   *Cache
+	started time.Time
+	ready   atomic.Bool // set once the cache has been initialized
+}
+
+// newHealth creates the probes for cache, counting uptime from started
+func newHealth(cache *Cache, started time.Time) *health {
+	return &health{cache: cache, started: started}
+}
+
+// report that the server is alive along with its uptime and cache size
+func (h *health) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	jsonEncoder(w, r).Encode(struct {
+		Status        string `json:"status"`
+		UptimeSeconds int64  `json:"uptime_seconds"`
+		Size          int    `json:"size"`
+	}{"ok", int64(time.Since(h.started) / time.Second), h.cache.Len()})
+}
+
+// report whether the cache is ready to serve traffic
+func (h *health) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.ready.Load() {
+		writeError(w, r, "Cache is not ready", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	jsonEncoder(w, r).Encode(map[string]string{"status": "ready"})
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthProbes(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	c.Set("a", 1, 0)
+	probes := newHealth(c, time.Now().Add(-90*time.Second))
+
+	rec := httptest.NewRecorder()
+	probes.healthzHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	var health struct {
+		Status        string `json:"status"`
+		UptimeSeconds int64  `json:"uptime_seconds"`
+		Size          int    `json:"size"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || health.Status != "ok" || health.UptimeSeconds < 90 || health.Size != 1 {
+		t.Fatalf("/healthz: got status %d and %+v", rec.Code, health)
+	}
+
+	rec = httptest.NewRecorder()
+	probes.readyzHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("/readyz before ready: got status %d, want 503", rec.Code)
+	}
+	probes.ready.Store(true)
+	rec = httptest.NewRecorder()
+	probes.readyzHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/readyz once ready: got status %d, want 200", rec.Code)
+	}
+}
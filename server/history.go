@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultStatsHistory is how many samples are kept when StatsInterval is set
+// and StatsHistory is not
+const DefaultStatsHistory = 60
+
+// statsSample is the cache counters at one moment, an entry of /stats/history
+type statsSample struct {
+	Time      time.Time `json:"time"`
+	Hits      int64     `json:"hits"`
+	Misses    int64     `json:"misses"`
+	Size      int       `json:"size"`
+	Evictions int64     `json:"evictions"`
+}
+
+// statsHistory is a ring buffer of the latest samples, overwriting the oldest once full
+type statsHistory struct {
+	mutex   sync.Mutex
+	samples []statsSample
+	next    int // index the next sample is written to
+	full    bool
+}
+
+// newStatsHistory creates a history holding up to size samples
+func newStatsHistory(size int) *statsHistory {
+	return &statsHistory{samples: make([]statsSample, size)}
+}
+
+// add records sample, dropping the oldest one if the history is full
+func (h *statsHistory) add(sample statsSample) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.samples[h.next] = sample
+	h.next = (h.next + 1) % len(h.samples)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// latest returns up to limit of the most recent samples, oldest first, or
+// every sample held if limit is not positive
+func (h *statsHistory) latest(limit int) []statsSample {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	ordered := h.samples[:h.next]
+	if h.full {
+		ordered = append(append([]statsSample{}, h.samples[h.next:]...), ordered...)
+	}
+	if limit > 0 && limit < len(ordered) {
+		ordered = ordered[len(ordered)-limit:]
+	}
+	return append([]statsSample{}, ordered...)
+}
+
+// StatsHistory returns up to limit of the most recent samples taken every
+// StatsInterval, oldest first, or all that are kept if limit is not positive.
+// It is empty when sampling is off.
+func (c *Cache) StatsHistory(limit int) []statsSample {
+	if c.history == nil {
+		return []statsSample{}
+	}
+	return c.history.latest(limit)
+}
+
+// sample adds the current counters to the history. It must only be called
+// when sampling is on.
+func (c *Cache) sample() {
+	stats := c.Stats()
+	c.history.add(statsSample{
+		Time:      c.clock.Now(),
+		Hits:      stats.Hits,
+		Misses:    stats.Misses,
+		Size:      stats.Size,
+		Evictions: stats.Evictions,
+	})
+}
+
+// startSampler samples the counters every interval until Close is called.
+// newCache runs it in its own goroutine, so it must not spawn another one.
+func (c *Cache) startSampler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	c.runSampler(ticker.C)
+}
+
+// runSampler samples the counters on every tick until Close is called, so
+// that tests can drive it without waiting on a ticker
+func (c *Cache) runSampler(tick <-chan time.Time) {
+	defer close(c.sampled)
+	for {
+		select {
+		case <-tick:
+			c.sample()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// list the latest counter samples, oldest first, at most ?limit= of them
+func (c *Cache) statsHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", 0)
+	if err != nil || limit < 0 {
+		writeError(w, r, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	jsonEncoder(w, r).Encode(c.StatsHistory(limit))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStatsHistoryRing(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := newStatsHistory(2)
+	if got := h.latest(0); len(got) != 0 {
+		t.Fatalf("empty history: got %+v", got)
+	}
+	for i := 0; i < 3; i++ {
+		h.add(statsSample{Time: start.Add(time.Duration(i) * time.Second), Hits: int64(i)})
+	}
+	samples := h.latest(0)
+	if len(samples) != 2 || samples[0].Hits != 1 || samples[1].Hits != 2 {
+		t.Fatalf("got %+v, want the latest 2 oldest first", samples)
+	}
+	if latest := h.latest(1); len(latest) != 1 || latest[0] != samples[1] {
+		t.Fatalf("latest(1): got %+v, want the newest sample", latest)
+	}
+}
+
+func TestStatsHistoryHandler(t *testing.T) {
+	c := NewCacheWithOptions(Options{StatsInterval: time.Hour})
+	defer c.Close()
+	c.Set("a", 1, 0)
+	c.Get("a")
+	c.sample()
+	c.sample()
+
+	h := NewServer(c)
+	if rec := serve(h, http.MethodGet, "/stats/history?limit=1", ""); rec.Code != http.StatusOK || rec.Body.String()[0] != '[' {
+		t.Fatalf("got status %d and body %q", rec.Code, rec.Body.String())
+	}
+	if rec := serve(h, http.MethodGet, "/stats/history?limit=-1", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("negative limit: got status %d, want 400", rec.Code)
+	}
+	samples := c.StatsHistory(0)
+	if len(samples) != 2 || samples[1].Hits != 1 || samples[1].Size != 1 {
+		t.Fatalf("got %+v, want 2 samples of the counters", samples)
+	}
+
+	off := NewCache()
+	defer off.Close()
+	if got := off.StatsHistory(0); got == nil || len(got) != 0 {
+		t.Fatalf("sampling off: got %#v, want an empty history", got)
+	}
+}
+
+func TestSamplerRecordsEveryTick(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	c := NewCacheWithOptions(Options{Clock: clock})
+	c.history = newStatsHistory(2)
+	c.sampled = make(chan struct{})
+	tick := make(chan time.Time)
+	go c.runSampler(tick)
+	// Every tick is sent at a new time, so a sample is done once it is the newest
+	sampleNow := func() {
+		tick <- clock.Now()
+		for latest := c.StatsHistory(1); len(latest) == 0 || !latest[0].Time.Equal(clock.Now()); latest = c.StatsHistory(1) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	c.Set("a", 1, 0)
+	c.Get("a")
+	sampleNow()
+	clock.Advance(time.Second)
+	c.Get("missing")
+	sampleNow()
+	clock.Advance(time.Second)
+	c.Get("a")
+	sampleNow()
+	c.Close()
+
+	samples := c.StatsHistory(0)
+	if len(samples) != 2 {
+		t.Fatalf("got %d samples, want the latest 2", len(samples))
+	}
+	if got := samples[0]; !got.Time.Equal(start.Add(time.Second)) || got.Hits != 1 || got.Misses != 1 || got.Size != 1 {
+		t.Errorf("older sample: got %+v", got)
+	}
+	if got := samples[1]; !got.Time.Equal(start.Add(2*time.Second)) || got.Hits != 2 || got.Misses != 1 {
+		t.Errorf("newer sample: got %+v", got)
+	}
+	if latest := c.StatsHistory(1); len(latest) != 1 || latest[0] != samples[1] {
+		t.Errorf("StatsHistory(1): got %+v, want the newest sample", latest)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// ErrNotInteger is returned by Increment when the stored value is not an integer
+var ErrNotInteger = errors.New("value is not an integer")
+
+// Increment adds delta to the integer stored under key and returns the new
+// value. A missing key starts from zero and never expires; an existing key
+// keeps its expiration, tags and cost. The read and write happen under a
+// single write lock. A missing key the cache refuses to add returns the error
+// TrySet would give for it.
+func (c *Cache) Increment(key string, delta int64) (int64, error) {
+	if c.parts != nil {
+		return c.part(key).Increment(key, delta)
+	}
+	c.lock()
+	defer c.unlock()
+	item, found := c.lookup(key)
+	if !found {
+		if err := c.admit(key, delta); err != nil {
+			return 0, err
+		}
+		c.set(key, delta, NoExpiration)
+		return delta, nil
+	}
+	current, ok := toInt64(item.load())
+	if !ok {
+		return 0, ErrNotInteger
+	}
+	c.update(key, item, current+delta)
+	return current + delta, nil
+}
+
+// Decrement subtracts delta from the integer stored under key, see Increment
+func (c *Cache) Decrement(key string, delta int64) (int64, error) {
+	return c.Increment(key, -delta)
+}
+
+// toInt64 converts the integer types, json.Number values holding integers,
+// and floats holding whole numbers to an int64
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), v <= math.MaxInt64
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	case float32:
+		return int64(v), v == float32(math.Trunc(float64(v)))
+	case float64:
+		return int64(v), v == math.Trunc(v) && math.Abs(v) < 1<<63
+	}
+	return 0, false
+}
+
+// increment the integer stored under the key by delta, 1 if not given
+func (c *Cache) incrHandler(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeError(w, r, "Key is required", http.StatusBadRequest)
+		return
+	}
+	delta := int64(1)
+	if raw := r.URL.Query().Get("delta"); raw != "" {
+		var err error
+		delta, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			writeError(w, r, "Invalid delta", http.StatusBadRequest)
+			return
+		}
+	}
+
+	value, err := c.Increment(key, delta)
+	switch {
+	case errors.Is(err, ErrNotInteger):
+		writeError(w, r, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		writeSetError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	jsonEncoder(w, r).Encode(map[string]int64{"value": value})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIncrementConcurrent(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := c.Increment("n", 1); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if got, _ := c.Get("n"); got != int64(5000) {
+		t.Fatalf("got %v, want 5000", got)
+	}
+	if got, err := c.Decrement("n", 1000); err != nil || got != 4000 {
+		t.Fatalf("Decrement: got %d, %v; want 4000", got, err)
+	}
+}
+
+func TestIncrementNotInteger(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	for _, value := range []interface{}{"ten", 1.5, []int{1}} {
+		c.Set("n", value, 0)
+		if _, err := c.Increment("n", 1); !errors.Is(err, ErrNotInteger) {
+			t.Errorf("%v: got %v, want ErrNotInteger", value, err)
+		}
+	}
+}
+
+func TestIncrHandler(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+
+	if rec := serve(h, http.MethodPost, "/incr?key=n", ""); strings.TrimSpace(rec.Body.String()) != `{"value":1}` {
+		t.Fatalf("got status %d and body %q", rec.Code, rec.Body.String())
+	}
+	if rec := serve(h, http.MethodPost, "/incr?key=n&delta=-5", ""); strings.TrimSpace(rec.Body.String()) != `{"value":-4}` {
+		t.Fatalf("got status %d and body %q", rec.Code, rec.Body.String())
+	}
+	if rec := serve(h, http.MethodPost, "/incr?key=n&delta=x", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("bad delta: got status %d, want 400", rec.Code)
+	}
+	c.Set("s", "text", 0)
+	if rec := serve(h, http.MethodPost, "/incr?key=s", ""); rec.Code != http.StatusConflict {
+		t.Fatalf("string value: got status %d, want 409", rec.Code)
+	}
+}
+
+func TestIncrementKeepsTagsCostAndExpiration(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	c.SetWithTags("tagged", 1, time.Minute, "counters")
+	c.SetWithCost("costly", 1, 3, 0)
+	if _, err := c.Increment("tagged", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Increment("costly", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl, ok := c.TTL("tagged"); !ok || ttl <= 59*time.Second {
+		t.Errorf("TTL after Increment: got %s, %t", ttl, ok)
+	}
+	c.mutex.RLock()
+	cost, total := c.items["costly"].cost, c.cost
+	c.mutex.RUnlock()
+	if cost != 3 || total != 4 {
+		t.Errorf("got item cost %d and total %d, want 3 and 4", cost, total)
+	}
+	if n := c.InvalidateTag("counters"); n != 1 {
+		t.Fatalf("InvalidateTag removed %d keys, want 1", n)
+	}
+	if c.Has("tagged") {
+		t.Fatal("tagged key survived InvalidateTag")
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// inFlight counts the requests currently inside the API handlers of this
+// process and is reported by /stats
+var inFlight atomic.Int64
+
+// openStreams counts the long-lived streams, such as /events, that are open
+// in this process. They are reported by /stats apart from inFlight.
+var openStreams atomic.Int64
+
+// limitInFlight wraps h so at most max requests run at once. Requests over the
+// limit are turned away with 503 instead of queueing; max of zero only counts.
+// Requests for the streams paths stay open for as long as the client listens,
+// so they take no slot and are counted in openStreams instead.
+func limitInFlight(h http.Handler, max int, streams ...string) http.Handler {
+	var slots chan struct{}
+	if max > 0 {
+		slots = make(chan struct{}, max)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, path := range streams {
+			if r.URL.Path == path {
+				openStreams.Add(1)
+				defer openStreams.Add(-1)
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			default:
+				w.Header().Set("Retry-After", "1")
+				writeError(w, r, "Too many requests in flight", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		inFlight.Add(1)
+		defer inFlight.Add(-1)
+		h.ServeHTTP(w, r)
+	})
+}
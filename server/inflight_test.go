@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLimitInFlight(t *testing.T) {
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	h := limitInFlight(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}), 1)
+
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/get", nil))
+		close(done)
+	}()
+	<-entered
+	if got := inFlight.Load(); got != 1 {
+		t.Errorf("got %d requests in flight, want 1", got)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/get", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("got status %d and Retry-After %q, want 503 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	close(release)
+	<-done
+	if got := inFlight.Load(); got != 0 {
+		t.Errorf("got %d requests in flight after they finished, want 0", got)
+	}
+}
+
+func TestEventStreamsTakeNoInFlightSlot(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	server := httptest.NewServer(NewServerWithOptions(c, ServerOptions{MaxInFlight: 1}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/events", nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("stream %d: got status %d, want 200", i, resp.StatusCode)
+		}
+	}
+	if got := openStreams.Load(); got != 2 {
+		t.Errorf("got %d open streams, want 2", got)
+	}
+
+	resp, err := http.Get(server.URL + "/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("/stats with two streams open: got status %d, want 200", resp.StatusCode)
+	}
+	var stats serverStats
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	// The /stats request itself is the one in flight
+	if stats.Streams != 2 || stats.InFlight != 1 {
+		t.Fatalf("/stats reported %d streams and %d requests in flight, want 2 and 1", stats.Streams, stats.InFlight)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultMaxKeyLength is the longest key in bytes a cache accepts unless told otherwise
+const DefaultMaxKeyLength = 512
+
+// ErrEmptyKey is returned when storing a value under the empty key
+var ErrEmptyKey = errors.New("key is empty")
+
+// ErrKeyTooLong is returned when storing a value under a key longer than the cache allows
+var ErrKeyTooLong = errors.New("key is too long")
+
+// CheckKey reports whether the cache accepts key, returning ErrEmptyKey or
+// ErrKeyTooLong if it does not
+func (c *Cache) CheckKey(key string) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+	if len(key) > c.maxKeyLen {
+		return fmt.Errorf("%w: %d bytes, at most %d allowed", ErrKeyTooLong, len(key), c.maxKeyLen)
+	}
+	return nil
+}
+
+// ErrValueTooLarge is returned when storing a value whose estimated size is
+// over the MaxValueBytes of the cache
+var ErrValueTooLarge = errors.New("value is too large")
+
+// CheckValueSize reports whether the cache accepts a value of this size,
+// estimated as for MaxBytes, returning ErrValueTooLarge if it does not
+func (c *Cache) CheckValueSize(value interface{}) error {
+	if c.maxValue <= 0 {
+		return nil
+	}
+	if size := estimateSize(value); size > c.maxValue {
+		return fmt.Errorf("%w: %d bytes, at most %d allowed", ErrValueTooLarge, size, c.maxValue)
+	}
+	return nil
+}
+
+// ErrUnserializable is returned by SetChecked for a value that cannot be
+// encoded as JSON, such as a func or channel, when the cache checks values
+var ErrUnserializable = errors.New("value cannot be serialized")
+
+// CheckValue reports whether value can be encoded as JSON for snapshots and
+// responses, returning ErrUnserializable if it cannot
+func CheckValue(value interface{}) error {
+	if _, err := json.Marshal(value); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnserializable, err)
+	}
+	return nil
+}
+
+// SetChecked stores the value like Set, but returns an error instead of
+// dropping it when the key is rejected by CheckKey or the value by
+// CheckValueSize. In caches created with CheckValues it also rejects values
+// that fail CheckValue.
+func (c *Cache) SetChecked(key string, value interface{}, expiration time.Duration) error {
+	if err := c.CheckKey(key); err != nil {
+		return err
+	}
+	if err := c.CheckValueSize(value); err != nil {
+		return err
+	}
+	if c.checkVals {
+		if err := CheckValue(value); err != nil {
+			return err
+		}
+	}
+	return c.TrySet(key, value, expiration)
+}
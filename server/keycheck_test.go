@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestSetCheckedValues(t *testing.T) {
+	c := NewCacheWithOptions(Options{CheckValues: true})
+	defer c.Close()
+
+	rejected := map[string]interface{}{
+		"func":    func() {},
+		"channel": make(chan int),
+		"nan":     math.NaN(),
+		"nested":  map[string]interface{}{"f": func() {}},
+	}
+	for key, value := range rejected {
+		if err := c.SetChecked(key, value, 0); !errors.Is(err, ErrUnserializable) {
+			t.Errorf("%s: got %v, want ErrUnserializable", key, err)
+		}
+		if c.Has(key) {
+			t.Errorf("%s: rejected value stored", key)
+		}
+	}
+	if err := c.SetChecked("map", map[string]interface{}{"a": []int{1, 2}}, 0); err != nil {
+		t.Fatalf("map: %v", err)
+	}
+
+	unchecked := NewCache()
+	defer unchecked.Close()
+	if err := unchecked.SetChecked("func", func() {}, 0); err != nil {
+		t.Fatalf("cache without CheckValues rejected a func: %v", err)
+	}
+}
+
+func TestSetCheckedKeys(t *testing.T) {
+	c := NewCacheWithOptions(Options{MaxKeyLength: 4})
+	defer c.Close()
+	if err := c.SetChecked("", 1, 0); !errors.Is(err, ErrEmptyKey) {
+		t.Fatalf("empty key: got %v", err)
+	}
+	if err := c.SetChecked(strings.Repeat("k", 5), 1, 0); !errors.Is(err, ErrKeyTooLong) {
+		t.Fatalf("long key: got %v", err)
+	}
+	if err := c.SetChecked("kkkk", 1, 0); err != nil {
+		t.Fatalf("key at the limit: %v", err)
+	}
+}
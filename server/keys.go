@@ -0,0 +1,259 @@
+package main
+
+import (
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Keys returns all live keys in sorted order
+func (c *Cache) Keys() []string {
+	return c.KeysWithPrefix("")
+}
+
+// KeysWithPrefix returns the live keys starting with prefix in sorted order
+func (c *Cache) KeysWithPrefix(prefix string) []string {
+	if c.parts != nil {
+		keys := make([]string, 0)
+		for _, part := range c.parts {
+			keys = append(keys, part.KeysWithPrefix(prefix)...)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	now := c.clock.Now()
+	keys := make([]string, 0, len(c.items))
+	for key, item := range c.items {
+		if !item.expired(now) && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// MatchKeys returns the live keys matching the shell pattern, in sorted
+// order. The syntax is that of path.Match, so * and ? do not match a slash.
+// It returns path.ErrBadPattern if the pattern is malformed.
+func (c *Cache) MatchKeys(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if c.parts != nil {
+		keys := make([]string, 0)
+		for _, part := range c.parts {
+			matched, _ := part.MatchKeys(pattern)
+			keys = append(keys, matched...)
+		}
+		sort.Strings(keys)
+		return keys, nil
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	now := c.clock.Now()
+	keys := make([]string, 0)
+	for key, item := range c.items {
+		// The pattern is known to be valid, so Match cannot fail
+		if matched, _ := path.Match(pattern, key); matched && !item.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// DeleteMatch removes every key matching the shell pattern, with the syntax
+// of MatchKeys, and returns how many were present
+func (c *Cache) DeleteMatch(pattern string) (int, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return 0, err
+	}
+	if c.parts != nil {
+		removed := 0
+		for _, part := range c.parts {
+			n, _ := part.DeleteMatch(pattern)
+			removed += n
+		}
+		return removed, nil
+	}
+	c.lock()
+	defer c.unlock()
+	removed := 0
+	for key, item := range c.items {
+		if matched, _ := path.Match(pattern, key); matched {
+			c.removeItem(key, item)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// Range calls fn for every live item, most recently used first, until fn
+// returns false. A sharded cache visits one shard after another in that
+// order. It holds the read lock throughout without copying the items, so fn
+// must not call any method of the cache: a write, or a read while a writer
+// is waiting, would deadlock. Use Keys followed by Get for that.
+func (c *Cache) Range(fn func(key string, value interface{}) bool) {
+	if c.parts != nil {
+		more := true
+		for _, part := range c.parts {
+			part.Range(func(key string, value interface{}) bool {
+				more = fn(key, value)
+				return more
+			})
+			if !more {
+				return
+			}
+		}
+		return
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	now := c.clock.Now()
+	for e := c.order.Front(); e != nil; e = e.Next() {
+		key := e.Value.(string)
+		item := c.items[key]
+		if item.expired(now) {
+			continue
+		}
+		if !fn(key, item.load()) {
+			return
+		}
+	}
+}
+
+// DeletePrefix removes every key starting with prefix and returns how many were removed
+func (c *Cache) DeletePrefix(prefix string) int {
+	if c.parts != nil {
+		removed := 0
+		for _, part := range c.parts {
+			removed += part.DeletePrefix(prefix)
+		}
+		return removed
+	}
+	c.lock()
+	defer c.unlock()
+	removed := 0
+	for key, item := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.removeItem(key, item)
+			removed++
+		}
+	}
+	return removed
+}
+
+// list the live keys, optionally only those starting with ?prefix= or
+// matching the shell pattern ?pattern=
+func (c *Cache) keysHandler(w http.ResponseWriter, r *http.Request) {
+	prefix, pattern := r.URL.Query().Get("prefix"), r.URL.Query().Get("pattern")
+	if prefix != "" && pattern != "" {
+		writeError(w, r, "Only one of prefix and pattern may be given", http.StatusBadRequest)
+		return
+	}
+	keys := c.KeysWithPrefix(prefix)
+	if pattern != "" {
+		var err error
+		if keys, err = c.MatchKeys(pattern); err != nil {
+			writeError(w, r, "Invalid pattern", http.StatusBadRequest)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	jsonEncoder(w, r).Encode(keys)
+}
+
+// delete every key starting with ?prefix=
+func (c *Cache) deletePrefixHandler(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	if prefix == "" {
+		writeError(w, r, "Prefix is required", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	jsonEncoder(w, r).Encode(map[string]int{"deleted": c.DeletePrefix(prefix)})
+}
+
+// RefreshPrefix gives every live key starting with prefix the expiration ttl,
+// as UpdateTTL does for one key, and returns how many keys were refreshed.
+// It takes the lock once for the whole group, or once per shard.
+func (c *Cache) RefreshPrefix(prefix string, ttl time.Duration) int {
+	if c.parts != nil {
+		refreshed := 0
+		for _, part := range c.parts {
+			refreshed += part.RefreshPrefix(prefix, ttl)
+		}
+		return refreshed
+	}
+	c.lock()
+	defer c.unlock()
+	now := c.clock.Now()
+	expiration := c.expiresAt(ttl)
+	refreshed := 0
+	for key, item := range c.items {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if item.expired(now) {
+			c.evictExpired(key, item)
+			continue
+		}
+		c.setExpiration(item, expiration)
+		refreshed++
+	}
+	return refreshed
+}
+
+// give every key starting with ?prefix= a new expiration
+func (c *Cache) touchPrefixHandler(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	if prefix == "" {
+		writeError(w, r, "Prefix is required", http.StatusBadRequest)
+		return
+	}
+	ttl, err := time.ParseDuration(r.URL.Query().Get("ttl"))
+	if err != nil {
+		writeError(w, r, "Invalid ttl duration", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	jsonEncoder(w, r).Encode(map[string]int{"touched": c.RefreshPrefix(prefix, ttl)})
+}
+
+// IdleSince returns how long ago key was last read or written, and whether
+// it is live. Unlike TTL it measures use rather than remaining lifetime.
+func (c *Cache) IdleSince(key string) (time.Duration, bool) {
+	if c.parts != nil {
+		return c.part(key).IdleSince(key)
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	item, found := c.items[key]
+	now := c.clock.Now()
+	if !found || item.expired(now) {
+		return 0, false
+	}
+	return time.Duration(now.UnixNano() - item.accessed), true
+}
+
+// report how long ago the key was last read or written
+func (c *Cache) idleHandler(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeError(w, r, "Key is required", http.StatusBadRequest)
+		return
+	}
+
+	idle, ok := c.IdleSince(key)
+	if !ok {
+		writeError(w, r, "Key not found or expired", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	jsonEncoder(w, r).Encode(map[string]float64{"idle_seconds": idle.Seconds()})
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeys(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	c.Set("user:2", 1, 0)
+	c.Set("user:1", 1, time.Minute)
+	c.Set("user:gone", 1, 50*time.Millisecond)
+	c.Set("order:1", 1, 0)
+	time.Sleep(100 * time.Millisecond)
+
+	if got, want := c.Keys(), []string{"order:1", "user:1", "user:2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys: got %v, want %v", got, want)
+	}
+	if got, want := c.KeysWithPrefix("user:"), []string{"user:1", "user:2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("KeysWithPrefix: got %v, want %v", got, want)
+	}
+	if got := c.KeysWithPrefix("none:"); got == nil || len(got) != 0 {
+		t.Errorf("KeysWithPrefix with no match: got %#v, want an empty slice", got)
+	}
+
+	var listed []string
+	rec := serve(NewServer(c), http.MethodGet, "/keys?prefix=user:", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil || !reflect.DeepEqual(listed, []string{"user:1", "user:2"}) {
+		t.Errorf("/keys: got %q, %v", rec.Body.String(), err)
+	}
+}
+
+func TestDeletePrefix(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	for _, key := range []string{"user:1", "user:2", "users", "order:1"} {
+		c.Set(key, 1, 0)
+	}
+
+	if n := c.DeletePrefix("user:"); n != 2 {
+		t.Fatalf("deleted %d keys, want 2", n)
+	}
+	if got, want := c.Keys(), []string{"order:1", "users"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	h := NewServer(c)
+	rec := serve(h, http.MethodPost, "/delete-prefix?prefix=order:", "")
+	if strings.TrimSpace(rec.Body.String()) != `{"deleted":1}` {
+		t.Fatalf("/delete-prefix: got status %d and body %q", rec.Code, rec.Body.String())
+	}
+	if rec := serve(h, http.MethodPost, "/delete-prefix", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("no prefix: got status %d, want 400 rather than deleting everything", rec.Code)
+	}
+	if c.Len() != 1 {
+		t.Fatalf("got %d keys left, want 1", c.Len())
+	}
+}
+
+func TestRange(t *testing.T) {
+	c := NewCacheWithOptions(Options{LazyExpiration: true})
+	defer c.Close()
+	c.Set("a", 1, 0)
+	c.Set("brief", 2, 10*time.Millisecond)
+	c.Set("b", 3, 0)
+	c.Set("c", 4, 0)
+	time.Sleep(20 * time.Millisecond)
+
+	var keys []string
+	sum := 0
+	c.Range(func(key string, value interface{}) bool {
+		keys = append(keys, key)
+		sum += value.(int)
+		return true
+	})
+	if !reflect.DeepEqual(keys, []string{"c", "b", "a"}) || sum != 8 {
+		t.Fatalf("visited %v with sum %d, want the live keys most recent first", keys, sum)
+	}
+
+	visited := 0
+	c.Range(func(string, interface{}) bool {
+		visited++
+		return visited < 2
+	})
+	if visited != 2 {
+		t.Fatalf("visited %d items after fn returned false, want 2", visited)
+	}
+}
+
+func TestIdleSince(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+
+	c.Set("k", 1, 0)
+	time.Sleep(20 * time.Millisecond)
+	idle, ok := c.IdleSince("k")
+	if !ok || idle < 20*time.Millisecond {
+		t.Fatalf("after a write: got %s, %v, want at least 20ms", idle, ok)
+	}
+	c.Peek("k")
+	if again, _ := c.IdleSince("k"); again < idle {
+		t.Fatalf("Peek reset the idle time to %s", again)
+	}
+	c.Get("k")
+	if idle, _ := c.IdleSince("k"); idle >= 20*time.Millisecond {
+		t.Fatalf("after a read: got %s, want it reset", idle)
+	}
+
+	rec := serve(h, http.MethodGet, "/idle?key=k", "")
+	var body map[string]float64
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["idle_seconds"] < 0 || body["idle_seconds"] > 1 {
+		t.Fatalf("/idle: got %q, %v", rec.Body.String(), err)
+	}
+	if rec := serve(h, http.MethodGet, "/idle?key=missing", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("/idle of a missing key: status %d, want 404", rec.Code)
+	}
+}
+
+func TestRefreshPrefix(t *testing.T) {
+	c := NewCacheWithOptions(Options{LazyExpiration: true})
+	defer c.Close()
+	h := NewServer(c)
+	c.Set("session:1", 1, time.Second)
+	c.Set("session:2", 2, 0)
+	c.Set("session:old", 3, time.Millisecond)
+	c.Set("user:1", 4, time.Second)
+	time.Sleep(10 * time.Millisecond)
+
+	if refreshed := c.RefreshPrefix("session:", time.Hour); refreshed != 2 {
+		t.Fatalf("refreshed %d keys, want the 2 live sessions", refreshed)
+	}
+	for _, key := range []string{"session:1", "session:2"} {
+		if ttl, _ := c.TTL(key); ttl <= time.Hour-time.Second || ttl > time.Hour {
+			t.Errorf("%s: TTL %s, want 1h", key, ttl)
+		}
+	}
+	if ttl, _ := c.TTL("user:1"); ttl >= time.Second {
+		t.Errorf("user:1 was refreshed to %s", ttl)
+	}
+	if c.Has("session:old") {
+		t.Error("an expired key was revived")
+	}
+
+	rec := serve(h, http.MethodPost, "/touch-prefix?prefix=user:&ttl=1m", "")
+	var body map[string]int
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["touched"] != 1 {
+		t.Fatalf("/touch-prefix: got %q, %v", rec.Body.String(), err)
+	}
+	if ttl, _ := c.TTL("user:1"); ttl <= time.Minute-time.Second || ttl > time.Minute {
+		t.Fatalf("user:1 TTL %s after /touch-prefix", ttl)
+	}
+	for _, query := range []string{"ttl=1m", "prefix=user:&ttl=soon"} {
+		if rec := serve(h, http.MethodPost, "/touch-prefix?"+query, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("/touch-prefix?%s: status %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestMatchKeys(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+	for _, key := range []string{"user:1", "user:2", "user:10", "user:1/avatar", "order:1"} {
+		c.Set(key, 1, 0)
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"user:*", []string{"user:1", "user:10", "user:2"}},
+		{"user:?", []string{"user:1", "user:2"}},
+		{"user:[12]", []string{"user:1", "user:2"}},
+		{"*:1", []string{"order:1", "user:1"}},
+		{"user:*/*", []string{"user:1/avatar"}},
+		{"nothing*", []string{}},
+	}
+	for _, tt := range tests {
+		got, err := c.MatchKeys(tt.pattern)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, %v, want %v", tt.pattern, got, err, tt.want)
+		}
+	}
+	if _, err := c.MatchKeys("user:[1"); !errors.Is(err, path.ErrBadPattern) {
+		t.Fatalf("malformed pattern: got %v, want path.ErrBadPattern", err)
+	}
+
+	var listed []string
+	rec := serve(h, http.MethodGet, "/keys?pattern=user:%3F", "")
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil || !reflect.DeepEqual(listed, []string{"user:1", "user:2"}) {
+		t.Fatalf("/keys?pattern=: got %q, %v", rec.Body.String(), err)
+	}
+	for _, query := range []string{"pattern=user:[1", "pattern=*&prefix=user:"} {
+		if rec := serve(h, http.MethodGet, "/keys?"+query, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("/keys?%s: status %d, want 400", query, rec.Code)
+		}
+	}
+}
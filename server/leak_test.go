@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestMain fails the run if any test leaves a goroutine of this package behind
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if code == 0 {
+		if leaked := leakedGoroutines(5 * time.Second); leaked != "" {
+			fmt.Fprintf(os.Stderr, "goroutines left running after the tests:\n%s", leaked)
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+// packagePath prefixes the functions of this package in stack traces
+const packagePath = "github.com/vinaycharlie01/LRUcache/server."
+
+// leakedGoroutines waits up to timeout for the goroutines running or started
+// by code of this package to exit, and returns the stacks of those that did not
+func leakedGoroutines(timeout time.Duration) string {
+	deadline := time.Now().Add(timeout)
+	for {
+		buf := make([]byte, 1<<20)
+		buf = buf[:runtime.Stack(buf, true)]
+		var leaked [][]byte
+		for _, stack := range bytes.Split(buf, []byte("\n\n")) {
+			if bytes.Contains(stack, []byte(packagePath+"TestMain(")) {
+				continue
+			}
+			if bytes.Contains(stack, []byte(packagePath)) {
+				leaked = append(leaked, stack)
+			}
+		}
+		if len(leaked) == 0 {
+			return ""
+		}
+		if time.Now().After(deadline) {
+			return string(bytes.Join(leaked, []byte("\n\n")))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCloseLeavesNoGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	m := NewManager(Options{})
+	for _, name := range []string{"a", "b", "c"} {
+		m.Namespace(name).Set("k", 1, time.Minute)
+	}
+	closers := []interface{ Close() }{
+		m,
+		NewCache(),
+		NewCacheWithOptions(Options{StatsInterval: time.Millisecond}),
+		NewCacheWithOptions(Options{Capacity: 100, Shards: 4, StatsInterval: time.Millisecond}),
+		NewRingCache(Options{}, "a", "b", "c"),
+	}
+	if runtime.NumGoroutine() <= before {
+		t.Fatal("no background goroutines were started")
+	}
+	for _, c := range closers {
+		c.Close()
+	}
+
+	// Goroutines that have signalled they are done may take a moment to exit
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if leaked := runtime.NumGoroutine() - before; leaked > 0 {
+		buf := make([]byte, 1<<16)
+		t.Fatalf("%d goroutines left after Close:\n%s", leaked, buf[:runtime.Stack(buf, true)])
+	}
+}
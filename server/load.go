@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// GetOrLoad returns the live value for key, calling loader to produce and
+// store it on a miss. Concurrent misses for the same key share a single call
+// to loader. Errors from loader are returned to every waiting caller and
+// nothing is stored. Keys recorded as absent with SetMiss, for example by a
+// loader that found nothing, return ErrCachedMiss without calling loader.
+func (c *Cache) GetOrLoad(key string, ttl time.Duration, loader func() (interface{}, error)) (interface{}, error) {
+	value, err := c.Fetch(key)
+	if err == nil || errors.Is(err, ErrCachedMiss) {
+		return value, err
+	}
+	value, err, _ = c.loads.Do(key, func() (interface{}, error) {
+		// A caller that missed just before another finished loading finds its value here
+		if value, ok := c.Peek(key); ok {
+			return value, nil
+		}
+		if c.hasCachedMiss(key) {
+			return nil, ErrCachedMiss
+		}
+		value, err := loader()
+		if err != nil {
+			return nil, err
+		}
+		c.Set(key, value, ttl)
+		return value, nil
+	})
+	return value, err
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoadSharesOneCall(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func() (interface{}, error) {
+		calls.Add(1)
+		<-release
+		return "loaded", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan interface{}, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := c.GetOrLoad("k", time.Minute, loader)
+			if err != nil {
+				t.Error(err)
+			}
+			results <- value
+		}()
+	}
+	// Give the callers time to pile up behind the first load
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if calls.Load() != 1 {
+		t.Fatalf("loader called %d times, want 1", calls.Load())
+	}
+	for value := range results {
+		if value != "loaded" {
+			t.Fatalf("caller got %v", value)
+		}
+	}
+	if value, _ := c.Get("k"); value != "loaded" {
+		t.Fatalf("stored %v", value)
+	}
+	if _, err := c.GetOrLoad("k", time.Minute, loader); err != nil || calls.Load() != 1 {
+		t.Fatalf("a hit called the loader again: %v, %d calls", err, calls.Load())
+	}
+}
+
+func TestGetOrLoadError(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	failed := errors.New("backend down")
+	if _, err := c.GetOrLoad("k", 0, func() (interface{}, error) { return nil, failed }); !errors.Is(err, failed) {
+		t.Fatalf("got %v, want the loader error", err)
+	}
+	if c.Has("k") {
+		t.Fatal("a failed load stored a value")
+	}
+}
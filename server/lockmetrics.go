@@ -0,0 +1,46 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// lockBuckets spans lock latencies from a microsecond to about a second
+var lockBuckets = prometheus.ExponentialBuckets(1e-6, 4, 11)
+
+// lockMetrics records how long writers wait for the cache lock and how long they hold it
+type lockMetrics struct {
+	wait     prometheus.Histogram
+	hold     prometheus.Histogram
+	lockedAt time.Time // when the current holder acquired the lock
+}
+
+// newLockMetrics creates the lock histograms
+func newLockMetrics() *lockMetrics {
+	return &lockMetrics{
+		wait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "lrucache_lock_wait_seconds",
+			Help:    "Time spent waiting to acquire the cache write lock.",
+			Buckets: lockBuckets,
+		}),
+		hold: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "lrucache_lock_hold_seconds",
+			Help:    "Time the cache write lock was held by an operation.",
+			Buckets: lockBuckets,
+		}),
+	}
+}
+
+// lock acquires the write lock, timing the wait when lock metrics are on.
+// Release it with unlock.
+func (c *Cache) lock() {
+	if c.locks == nil {
+		c.mutex.Lock()
+		return
+	}
+	start := time.Now()
+	c.mutex.Lock()
+	c.locks.lockedAt = time.Now()
+	c.locks.wait.Observe(c.locks.lockedAt.Sub(start).Seconds())
+}
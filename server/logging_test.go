@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// capturingHandler is a slog.Handler that keeps every record it is given
+type capturingHandler struct {
+	mutex   sync.Mutex
+	records []slog.Record
+}
+
+func (h *capturingHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h *capturingHandler) WithAttrs([]slog.Attr) slog.Handler       { return h }
+func (h *capturingHandler) WithGroup(string) slog.Handler            { return h }
+
+func (h *capturingHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.records = append(h.records, r)
+	return nil
+}
+
+// find returns the attributes of every record with message msg
+func (h *capturingHandler) find(msg string) []map[string]slog.Value {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	var found []map[string]slog.Value
+	for _, r := range h.records {
+		if r.Message != msg {
+			continue
+		}
+		attrs := make(map[string]slog.Value)
+		r.Attrs(func(a slog.Attr) bool {
+			attrs[a.Key] = a.Value
+			return true
+		})
+		found = append(found, attrs)
+	}
+	return found
+}
+
+func TestLogsEvictions(t *testing.T) {
+	h := &capturingHandler{}
+	c := NewCacheWithOptions(Options{Capacity: 1, Logger: slog.New(h)})
+	defer c.Close()
+
+	c.Set("old", 1, 0)
+	c.Set("new", 2, 0)
+	evictions := h.find("cache eviction")
+	if len(evictions) != 1 || evictions[0]["key"].String() != "old" {
+		t.Fatalf("got eviction records %v, want one with key=old", evictions)
+	}
+	if sets := h.find("cache set"); len(sets) != 2 {
+		t.Errorf("got %d cache set records, want 2", len(sets))
+	}
+	c.Get("new")
+	if gets := h.find("cache get"); len(gets) != 1 || gets[0]["key"].String() != "new" || !gets[0]["hit"].Bool() {
+		t.Errorf("got cache get records %v, want a hit for new", gets)
+	}
+}
+
+func TestLogsFailedRequests(t *testing.T) {
+	h := &capturingHandler{}
+	c := NewCacheWithOptions(Options{Logger: slog.New(h)})
+	defer c.Close()
+	server := NewServer(c)
+
+	serve(server, http.MethodGet, "/get?key=missing", "")
+	c.Set("a", 1, 0)
+	serve(server, http.MethodGet, "/get?key=a", "")
+	failed := h.find("request failed")
+	if len(failed) != 1 {
+		t.Fatalf("got %d request failed records, want 1", len(failed))
+	}
+	if got := failed[0]; got["method"].String() != http.MethodGet || got["path"].String() != "/get" || got["status"].Int64() != http.StatusNotFound {
+		t.Fatalf("got %v, want GET /get answered 404", got)
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	if logger, err := newLogger(&bytes.Buffer{}, ""); logger != nil || err != nil {
+		t.Fatalf("empty level: got %v, %v; want no logger", logger, err)
+	}
+	if _, err := newLogger(&bytes.Buffer{}, "loud"); err == nil {
+		t.Fatal("unknown level accepted")
+	}
+
+	var out bytes.Buffer
+	logger, err := newLogger(&out, "warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCacheWithOptions(Options{Logger: logger})
+	defer c.Close()
+	c.Set("a", 1, 0)
+	c.Set("", 1, 0)
+	if got := out.String(); strings.Contains(got, `msg="cache set"`) || !strings.Contains(got, "cache set rejected") {
+		t.Fatalf("warn logger wrote %q, want only the rejected set", got)
+	}
+}
+
+func TestLoadConfigLogLevel(t *testing.T) {
+	env := map[string]string{"LOG_LEVEL": "debug"}
+	cfg, err := loadConfig(nil, func(key string) string { return env[key] })
+	if err != nil || cfg.LogLevel != "debug" {
+		t.Fatalf("got %q, %v; want debug from the environment", cfg.LogLevel, err)
+	}
+	cfg, err = loadConfig([]string{"-log-level", "INFO"}, func(key string) string { return env[key] })
+	if err != nil || cfg.LogLevel != "INFO" {
+		t.Fatalf("got %q, %v; want the flag to win", cfg.LogLevel, err)
+	}
+	if _, err := loadConfig([]string{"-log-level", "loud"}, func(string) string { return "" }); err == nil {
+		t.Fatal("unknown level accepted")
+	}
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"net"
 	"net/http"
-	"sync"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -18,124 +21,89 @@ import (
 // Good to have
 // ● Implementing concurrency in cache
 
-// CacheItem represents an item in the cache with expiration time
-type CacheItem struct {
-	value      interface{}
-	expiration int64
-}
-
-// Cache represents the cache structure
-type Cache struct {
-	items map[string]CacheItem
-	mutex sync.RWMutex
-}
-
-// NewCache creates a new cache instance
-func NewCache() *Cache {
-	cache := &Cache{
-		items: make(map[string]CacheItem),
-	}
-	go cache.startEvictionProcess()
-	return cache
-}
-
-// new key-value pair to the cache with an expiration time
-func (c *Cache) Set(key string, value interface{}, expiration time.Duration) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	c.items[key] = CacheItem{
-		value:      value,
-		expiration: time.Now().Add(expiration).Unix(),
-	}
-}
-
-// Get Method retrieves the value given key from the cache
-func (c *Cache) Get(key string) (interface{}, bool) {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
-	item, found := c.items[key]
-	if !found {
-		return nil, false
+func main() {
+	started := time.Now()
+	cfg, err := loadConfig(os.Args[1:], os.Getenv)
+	if err != nil {
+		fmt.Println("Invalid configuration:", err)
+		os.Exit(2)
 	}
-	if time.Now().Unix() > item.expiration {
-		// Evict expired item
-		delete(c.items, key)
-		return nil, false
+	fmt.Println("Configuration:", cfg)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger, _ := newLogger(os.Stderr, cfg.LogLevel)
+	manager := NewManager(Options{
+		Capacity:       cfg.Capacity,
+		Shards:         cfg.Shards,
+		DefaultTTL:     cfg.DefaultTTL,
+		LazyExpiration: cfg.Lazy,
+		CacheControl:   cfg.HTTPCache,
+		StatsInterval:  cfg.StatsEvery,
+		Logger:         logger,
+	})
+	defer manager.Close()
+	manager.SetMaxNamespaces(cfg.MaxNS)
+	cache := manager.Namespace(DefaultNamespace)
+	probes := newHealth(cache, started)
+
+	if cfg.Snapshot != "" {
+		if err := cache.LoadFromFile(cfg.Snapshot); err != nil {
+			fmt.Println("Failed to load snapshot:", err)
+		}
 	}
-	return item.value, true
-}
 
-// evicts expired items from the cache
-func (c *Cache) evictExpiredItems() {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	for key, item := range c.items {
-		if time.Now().Unix() > item.expiration {
-			delete(c.items, key)
+	//HTTP end Points and handlers
+	mux := http.NewServeMux()
+	mux.Handle("/", manager)
+	mux.HandleFunc("/healthz", allowMethods(probes.healthzHandler, http.MethodGet))
+	mux.HandleFunc("/readyz", allowMethods(probes.readyzHandler, http.MethodGet))
+	mux.HandleFunc("/version", allowMethods(versionHandler, http.MethodGet))
+	probes.ready.Store(true)
+
+	if cfg.GRPCAddr != "" {
+		listener, err := net.Listen("tcp", cfg.GRPCAddr)
+		if err != nil {
+			fmt.Println("Failed to start gRPC server:", err)
+			os.Exit(1)
 		}
+		fmt.Println("gRPC server listening on", cfg.GRPCAddr)
+		grpcServer := NewGRPCServer(cache)
+		defer grpcServer.GracefulStop()
+		go grpcServer.Serve(listener)
 	}
-}
 
-// startEvictionProcess starts a goroutine to periodically evict expired items from the cache
-func (c *Cache) startEvictionProcess() {
-	go func() {
-		for {
-			c.evictExpiredItems()
-			time.Sleep(1 * time.Second) // Check every second for expired items
+	if cfg.TextAddr != "" {
+		listener, err := net.Listen("tcp", cfg.TextAddr)
+		if err != nil {
+			fmt.Println("Failed to start text protocol server:", err)
+			os.Exit(1)
 		}
-	}()
-}
-
-func main() {
-
-	cache := NewCache()
-
-	//HTTP end Points and handlers
-	http.HandleFunc("/get", cache.getHandler)
-	http.HandleFunc("/set", cache.setHandler)
+		fmt.Println("Text protocol server listening on", cfg.TextAddr)
+		defer listener.Close()
+		go ServeText(listener, cache)
+	}
 
 	// Start HTTP server
-	fmt.Println("Server listening on port 8080")
-	http.ListenAndServe(":8080", nil)
-}
-
-// Get the value
-func (c *Cache) getHandler(w http.ResponseWriter, r *http.Request) {
-
-	key := r.URL.Query().Get("key")
-	if key == "" {
-		http.Error(w, "Key is required", http.StatusBadRequest)
-		return
+	fmt.Println("Server listening on", cfg.Addr)
+	srv := &http.Server{Addr: cfg.Addr, Handler: wrapHandler(mux, ServerOptions{
+		RateLimit:    cfg.RateLimit,
+		RateBurst:    cfg.RateBurst,
+		CORS:         cfg.CORS,
+		CORSOrigin:   cfg.CORSOrigin,
+		APIKey:       cfg.APIKey,
+		MaxBodyBytes: cfg.MaxBody,
+		MaxInFlight:  cfg.MaxFlight,
+	})}
+	if err := runServer(ctx, srv, shutdownTimeout); err != nil {
+		fmt.Println("Server stopped:", err)
 	}
+	fmt.Println("Shutting down")
 
-	value, ok := c.Get(key)
-	if !ok {
-		http.Error(w, "Key not found or expired", http.StatusNotFound)
-		return
+	if cfg.Snapshot != "" {
+		if err := cache.SaveToFile(cfg.Snapshot); err != nil {
+			fmt.Println("Failed to save snapshot:", err)
+		}
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(value)
-
 }
-
-// set the cache data
-func (c *Cache) setHandler(w http.ResponseWriter, r *http.Request) {
-	var data struct {
-		Key        string      `json:"key"`
-		Value      interface{} `json:"value"`
-		Expiration string      `json:"expiration"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	expiration, err := time.ParseDuration(data.Expiration)
-	if err != nil {
-		http.Error(w, "Invalid expiration duration", http.StatusBadRequest)
-		return
-	}
-	c.Set(data.Key, data.Value, expiration) // Expiration set to 5 seconds
-	w.WriteHeader(http.StatusCreated)
-	fmt.Fprintf(w, "Key %s set with value %s and expiration %s\n", data.Key, data.Value, expiration)
-}
\ No newline at end of file
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultNamespace is the namespace used by requests without ?ns=
+const DefaultNamespace = "default"
+
+// Manager holds several named caches that share one eviction goroutine.
+// Namespaces are created on first use with the options given to NewManager,
+// except for a default TTL set with SetNamespaceTTL. Requests naming a
+// namespace that does not exist yet create it too, up to the limit set with
+// SetMaxNamespaces.
+type Manager struct {
+	opts     Options
+	mutex    sync.RWMutex
+	caches   map[string]*Cache
+	handlers map[string]http.Handler
+	ttls     map[string]time.Duration // default TTL by namespace, overriding opts.DefaultTTL
+	created  int                      // namespaces created by requests
+	maxNew   int                      // namespaces requests may create besides DefaultNamespace, unlimited if zero
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewManager creates a manager whose namespaces are configured by opts
+func NewManager(opts Options) *Manager {
+	if opts.SweepInterval <= 0 {
+		opts.SweepInterval = DefaultSweepInterval
+	}
+	m := &Manager{
+		opts:     opts,
+		caches:   make(map[string]*Cache),
+		handlers: make(map[string]http.Handler),
+		ttls:     make(map[string]time.Duration),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if opts.LazyExpiration {
+		close(m.done)
+		return m
+	}
+	go m.startEvictionProcess()
+	return m
+}
+
+// Namespace returns the cache for name, creating it if it does not exist yet
+func (m *Manager) Namespace(name string) *Cache {
+	cache, _ := m.open(name, false)
+	return cache
+}
+
+// open returns the cache and handler for name, creating the namespace if it
+// does not exist yet. When onRequest is set and SetMaxNamespaces gave a limit,
+// namespaces besides DefaultNamespace are only created within it; a nil cache
+// is returned for any other.
+func (m *Manager) open(name string, onRequest bool) (*Cache, http.Handler) {
+	m.mutex.RLock()
+	cache, found := m.caches[name]
+	handler := m.handlers[name]
+	m.mutex.RUnlock()
+	if found {
+		return cache, handler
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if cache, found := m.caches[name]; found {
+		return cache, m.handlers[name]
+	}
+	if onRequest && name != DefaultNamespace {
+		if m.maxNew > 0 && m.created >= m.maxNew {
+			return nil, nil
+		}
+		m.created++
+	}
+	opts := m.opts
+	if ttl, found := m.ttls[name]; found {
+		opts.DefaultTTL = ttl
+	}
+	cache = newCache(opts)
+	// The manager sweeps its caches, so there is no loop for Close to wait on
+	close(cache.done)
+	m.caches[name] = cache
+	m.handlers[name] = NewServer(cache)
+	return cache, m.handlers[name]
+}
+
+// SetMaxNamespaces lets requests naming a namespace that does not exist
+// create only up to n of them, besides DefaultNamespace. Requests for others
+// are answered 404 unless the namespace was created with Namespace. Zero, the
+// default, removes the limit. It panics if n is negative.
+func (m *Manager) SetMaxNamespaces(n int) {
+	if n < 0 {
+		panic(fmt.Sprintf("namespace limit must not be negative, got %d", n))
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.maxNew = n
+}
+
+// SetNamespaceTTL gives the namespace name its own default TTL, which keys
+// set without an expiration inherit instead of the one in the manager
+// options. A per-key expiration still wins. It applies to a namespace that
+// already exists as well as to one created later.
+func (m *Manager) SetNamespaceTTL(name string, ttl time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.ttls[name] = ttl
+	if cache, found := m.caches[name]; found {
+		cache.SetDefaultTTL(ttl)
+	}
+}
+
+// ServeHTTP routes the request to the namespace named by ?ns=, DefaultNamespace if absent
+func (m *Manager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("ns")
+	if name == "" {
+		name = DefaultNamespace
+	}
+	cache, handler := m.open(name, true)
+	if cache == nil {
+		writeError(w, r, "Namespace not found", http.StatusNotFound)
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
+
+// startEvictionProcess sweeps every namespace until Close is called
+func (m *Manager) startEvictionProcess() {
+	defer close(m.done)
+	ticker := time.NewTicker(m.opts.SweepInterval)
+	defer ticker.Stop()
+	for {
+		m.mutex.RLock()
+		caches := make([]*Cache, 0, len(m.caches))
+		for _, cache := range m.caches {
+			caches = append(caches, cache)
+		}
+		m.mutex.RUnlock()
+		for _, cache := range caches {
+			cache.evictExpiredItems()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// Close stops the shared eviction process and waits for it to exit, then
+// closes every namespace so that none of them leaves a goroutine behind
+func (m *Manager) Close() {
+	m.closeOnce.Do(func() {
+		close(m.stop)
+	})
+	<-m.done
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for _, cache := range m.caches {
+		cache.Close()
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManagerNamespacesAreIsolated(t *testing.T) {
+	m := NewManager(Options{})
+	defer m.Close()
+
+	for _, ns := range []string{"a", "b"} {
+		body := `{"key":"k","value":"` + ns + `"}`
+		if rec := serve(m, http.MethodPost, "/set?ns="+ns, body); rec.Code != http.StatusCreated {
+			t.Fatalf("ns=%s: got status %d, body %q", ns, rec.Code, rec.Body.String())
+		}
+	}
+	for _, ns := range []string{"a", "b"} {
+		rec := serve(m, http.MethodGet, "/get?key=k&ns="+ns, "")
+		if got := strings.TrimSpace(rec.Body.String()); rec.Code != http.StatusOK || got != `"`+ns+`"` {
+			t.Errorf("ns=%s: got status %d and body %s", ns, rec.Code, got)
+		}
+	}
+	if rec := serve(m, http.MethodGet, "/get?key=k", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("default namespace: got status %d, want 404", rec.Code)
+	}
+	if m.Namespace("a") != m.Namespace("a") || m.Namespace("a") == m.Namespace("b") {
+		t.Fatal("Namespace does not return one cache per name")
+	}
+}
+
+func TestManagerSweepsEveryNamespace(t *testing.T) {
+	m := NewManager(Options{SweepInterval: 5 * time.Millisecond})
+	defer m.Close()
+
+	before := goroutinesStartedBy("NewCacheWithOptions")
+	a, b := m.Namespace("a"), m.Namespace("b")
+	a.Set("k", 1, 10*time.Millisecond)
+	b.Set("k", 1, 10*time.Millisecond)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		a.mutex.RLock()
+		b.mutex.RLock()
+		n := len(a.items) + len(b.items)
+		b.mutex.RUnlock()
+		a.mutex.RUnlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired keys were not swept within 2s")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := goroutinesStartedBy("NewCacheWithOptions"); got > before {
+		t.Fatalf("namespaces started %d eviction goroutines of their own", got-before)
+	}
+}
+
+func TestManagerNamespaceTTL(t *testing.T) {
+	m := NewManager(Options{DefaultTTL: time.Hour})
+	defer m.Close()
+	m.SetNamespaceTTL("sessions", time.Minute)
+	sessions := m.Namespace("sessions")
+
+	for _, body := range []string{`{"key":"s","value":1}`, `{"key":"long","value":1,"expiration":"2h"}`} {
+		req := httptest.NewRequest(http.MethodPost, "/set?ns=sessions", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		m.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if ttl, _ := sessions.TTL("s"); ttl <= 59*time.Second || ttl > time.Minute {
+		t.Fatalf("key without an expiration: TTL %s, want the namespace's 1m", ttl)
+	}
+	if ttl, _ := sessions.TTL("long"); ttl <= time.Hour+59*time.Minute {
+		t.Fatalf("per-key expiration: TTL %s, want 2h", ttl)
+	}
+
+	m.Namespace(DefaultNamespace).Set("d", 1, m.Namespace(DefaultNamespace).DefaultTTL())
+	if ttl, _ := m.Namespace(DefaultNamespace).TTL("d"); ttl <= 59*time.Minute {
+		t.Fatalf("other namespace: TTL %s, want the manager's 1h", ttl)
+	}
+
+	// A TTL set after the namespace exists applies to it too
+	m.SetNamespaceTTL(DefaultNamespace, 10*time.Second)
+	if ttl := m.Namespace(DefaultNamespace).DefaultTTL(); ttl != 10*time.Second {
+		t.Fatalf("existing namespace kept the default TTL %s", ttl)
+	}
+}
+
+func TestManagerUnknownNamespaceNotFound(t *testing.T) {
+	m := NewManager(Options{})
+	defer m.Close()
+	m.SetMaxNamespaces(1)
+
+	if rec := serve(m, http.MethodGet, "/set?key=a&value=b&ns=first", ""); rec.Code != http.StatusCreated {
+		t.Fatalf("namespace within the limit: got status %d, want %d", rec.Code, http.StatusCreated)
+	}
+	if rec := serve(m, http.MethodGet, "/get?key=a&ns=other", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("namespace beyond the limit: got status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	serve(m, http.MethodGet, "/get?key=a", "")
+	if _, found := m.caches[DefaultNamespace]; !found {
+		t.Fatal("default namespace was not created")
+	}
+	m.Namespace("other").Set("a", "b", 0)
+	if rec := serve(m, http.MethodGet, "/get?key=a&ns=other", ""); rec.Code != http.StatusOK {
+		t.Fatalf("namespace created with Namespace: got status %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestManagerMaxNamespaces(t *testing.T) {
+	m := NewManager(Options{})
+	defer m.Close()
+	m.SetMaxNamespaces(2)
+
+	for _, tc := range []struct {
+		ns   string
+		want int
+	}{
+		{"a", http.StatusCreated},
+		{"b", http.StatusCreated},
+		{"c", http.StatusNotFound},
+		{"a", http.StatusCreated},
+		{"", http.StatusCreated},
+	} {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/set?key=k&value=v&ns="+tc.ns, nil))
+		if rec.Code != tc.want {
+			t.Errorf("ns=%s: got status %d, want %d", tc.ns, rec.Code, tc.want)
+		}
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if len(m.caches) != 3 {
+		t.Fatalf("got %d namespaces, want 3", len(m.caches))
+	}
+	if _, found := m.caches["c"]; found {
+		t.Fatal("namespace c was created beyond the limit")
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	hitsDesc      = prometheus.NewDesc("lrucache_hits_total", "Number of Get calls that found a live key.", nil, nil)
+	missesDesc    = prometheus.NewDesc("lrucache_misses_total", "Number of Get calls that found no live key.", nil, nil)
+	setsDesc      = prometheus.NewDesc("lrucache_sets_total", "Number of values written to the cache.", nil, nil)
+	evictionsDesc = prometheus.NewDesc("lrucache_evictions_total", "Number of keys evicted by expiration or capacity pressure.", nil, nil)
+	sizeDesc      = prometheus.NewDesc("lrucache_size", "Number of live keys in the cache.", nil, nil)
+)
+
+// cacheCollector exports the cache counters to Prometheus, reading them at scrape time
+type cacheCollector struct {
+	cache *Cache
+}
+
+// Describe implements prometheus.Collector
+func (cc cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- hitsDesc
+	ch <- missesDesc
+	ch <- setsDesc
+	ch <- evictionsDesc
+	ch <- sizeDesc
+}
+
+// Collect implements prometheus.Collector
+func (cc cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	var hits, misses, sets, evictions int64
+	for _, shard := range cc.cache.shards() {
+		hits += shard.hits.Load()
+		misses += shard.misses.Load()
+		sets += shard.sets.Load()
+		evictions += shard.evictions.Load()
+	}
+	ch <- prometheus.MustNewConstMetric(hitsDesc, prometheus.CounterValue, float64(hits))
+	ch <- prometheus.MustNewConstMetric(missesDesc, prometheus.CounterValue, float64(misses))
+	ch <- prometheus.MustNewConstMetric(setsDesc, prometheus.CounterValue, float64(sets))
+	ch <- prometheus.MustNewConstMetric(evictionsDesc, prometheus.CounterValue, float64(evictions))
+	ch <- prometheus.MustNewConstMetric(sizeDesc, prometheus.GaugeValue, float64(cc.cache.Len()))
+}
+
+// metricsHandler serves the cache metrics in the Prometheus exposition format
+func (c *Cache) metricsHandler() http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(cacheCollector{cache: c})
+	if c.locks != nil {
+		registry.MustRegister(c.locks.wait, c.locks.hold)
+	}
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
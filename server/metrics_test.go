@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrape returns the /metrics exposition of h
+func scrape(t *testing.T, h http.Handler) string {
+	t.Helper()
+	rec := serve(h, http.MethodGet, "/metrics", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/metrics: got status %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	c := NewCacheWithCapacity(1)
+	defer c.Close()
+	h := NewServer(c)
+
+	c.Set("a", 1, time.Minute)
+	c.Get("a")
+	c.Get("missing")
+	body := scrape(t, h)
+	for _, line := range []string{
+		"lrucache_hits_total 1",
+		"lrucache_misses_total 1",
+		"lrucache_sets_total 1",
+		"lrucache_evictions_total 0",
+		"lrucache_size 1",
+	} {
+		if !strings.Contains(body, "\n"+line+"\n") {
+			t.Errorf("first scrape lacks %q", line)
+		}
+	}
+
+	c.Get("a")
+	c.Set("b", 2, time.Minute)
+	body = scrape(t, h)
+	for _, line := range []string{"lrucache_hits_total 2", "lrucache_sets_total 2", "lrucache_evictions_total 1"} {
+		if !strings.Contains(body, "\n"+line+"\n") {
+			t.Errorf("second scrape lacks %q", line)
+		}
+	}
+	if strings.Contains(body, "lrucache_lock_wait_seconds") {
+		t.Error("lock histograms are served without LockMetrics")
+	}
+}
+
+func TestLockMetrics(t *testing.T) {
+	c := NewCacheWithOptions(Options{LockMetrics: true})
+	defer c.Close()
+	h := NewServer(c)
+
+	for i := 0; i < 10; i++ {
+		c.Set("k", i, 0)
+		c.Get("k")
+	}
+	body := scrape(t, h)
+	for _, name := range []string{"lrucache_lock_wait_seconds", "lrucache_lock_hold_seconds"} {
+		var count int
+		found := false
+		for _, line := range strings.Split(body, "\n") {
+			if rest, ok := strings.CutPrefix(line, name+"_count "); ok {
+				fmt.Sscan(rest, &count)
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s is not served", name)
+			continue
+		}
+		if count < 20 {
+			t.Errorf("%s has %d samples after 20 locked operations", name, count)
+		}
+	}
+}
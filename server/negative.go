@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrCachedMiss is returned by Fetch and GetOrLoad for a key recorded as
+// absent with SetMiss
+var ErrCachedMiss = errors.New("key cached as missing")
+
+// SetMiss records that key has no value for ttl, so Fetch reports
+// ErrCachedMiss and GetOrLoad skips its loader until then. Storing a value
+// under key, deleting it or clearing the cache forgets the record.
+func (c *Cache) SetMiss(key string, ttl time.Duration) {
+	if c.parts != nil {
+		c.part(key).SetMiss(key, ttl)
+		return
+	}
+	c.lock()
+	defer c.unlock()
+	if item, found := c.items[key]; found {
+		c.removeItem(key, item)
+	}
+	if c.negative == nil {
+		c.negative = make(map[string]int64)
+	}
+	if len(c.negative) >= c.capacity {
+		c.pruneMisses()
+	}
+	c.negative[key] = c.expiresAt(ttl)
+}
+
+// hasCachedMiss reports whether key is recorded as absent
+func (c *Cache) hasCachedMiss(key string) bool {
+	if c.parts != nil {
+		return c.part(key).hasCachedMiss(key)
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.cachedMiss(key, c.clock.Now())
+}
+
+// cachedMiss reports whether key is recorded as absent at now. The caller must hold the lock.
+func (c *Cache) cachedMiss(key string, now time.Time) bool {
+	expiration, found := c.negative[key]
+	return found && (expiration == 0 || now.UnixNano() <= expiration)
+}
+
+// pruneMisses drops the expired miss records, and an arbitrary live one if
+// that frees no room, so there are never more of them than the capacity.
+// The caller must hold the write lock.
+func (c *Cache) pruneMisses() {
+	now := c.clock.Now()
+	for key := range c.negative {
+		if !c.cachedMiss(key, now) {
+			delete(c.negative, key)
+		}
+	}
+	for key := range c.negative {
+		if len(c.negative) < c.capacity {
+			break
+		}
+		delete(c.negative, key)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSetMiss(t *testing.T) {
+	c := NewCacheWithOptions(Options{LazyExpiration: true})
+	defer c.Close()
+
+	calls := 0
+	loader := func() (interface{}, error) { calls++; return "loaded", nil }
+
+	c.Set("k", 1, 0)
+	c.SetMiss("k", 10*time.Millisecond)
+	if c.Has("k") {
+		t.Fatal("SetMiss kept the stored value")
+	}
+	if _, err := c.Fetch("k"); !errors.Is(err, ErrCachedMiss) {
+		t.Fatalf("Fetch: got %v, want ErrCachedMiss", err)
+	}
+	if _, err := c.GetOrLoad("k", 0, loader); !errors.Is(err, ErrCachedMiss) || calls != 0 {
+		t.Fatalf("GetOrLoad: got %v after %d loads, want ErrCachedMiss without loading", err, calls)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if value, err := c.GetOrLoad("k", 0, loader); err != nil || value != "loaded" || calls != 1 {
+		t.Fatalf("after the miss expired: got %v, %v after %d loads", value, err, calls)
+	}
+
+	c.SetMiss("k", 0)
+	c.Set("k", 2, 0)
+	if value, err := c.Fetch("k"); err != nil || value != 2 {
+		t.Fatalf("Set did not forget the miss: got %v, %v", value, err)
+	}
+	c.SetMiss("gone", 0)
+	c.Delete("gone")
+	if _, err := c.Fetch("gone"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Delete did not forget the miss: got %v", err)
+	}
+}
+
+func TestSetMissBounded(t *testing.T) {
+	c := NewCacheWithCapacity(4)
+	defer c.Close()
+	for _, key := range []string{"a", "b", "c", "d", "e", "f"} {
+		c.SetMiss(key, 0)
+	}
+	if len(c.negative) > 4 {
+		t.Fatalf("%d miss records for a capacity of 4", len(c.negative))
+	}
+	if _, err := c.Fetch("f"); !errors.Is(err, ErrCachedMiss) {
+		t.Fatalf("newest miss: got %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshot is the on-disk form of the cache written by SaveToFile
+type snapshot struct {
+	SavedAt time.Time `json:"saved_at"`
+	Items   []entry   `json:"items"` // least recently used first
+}
+
+// SaveToFile writes every live item and its remaining TTL to path as JSON.
+// The snapshot is written and synced to a temporary file next to path and
+// then renamed over it, so a crash midway leaves the previous snapshot intact.
+func (c *Cache) SaveToFile(path string) error {
+	data, err := json.Marshal(c.snapshot())
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0o644)
+}
+
+// writeFileAtomic replaces path with data through a synced temporary file in the same directory
+func writeFileAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadFromFile restores items written by SaveToFile. The time since the
+// snapshot was taken counts against each TTL, and items that ran out in the
+// meantime are dropped. A missing file leaves the cache empty without error.
+func (c *Cache) LoadFromFile(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var snap snapshot
+	if err := (jsonCodec{}).Decode(bytes.NewReader(data), &snap); err != nil {
+		return err
+	}
+	_, err = c.restore(snap)
+	return err
+}
+
+// snapshot captures the live items, oldest first so restoring them keeps the recency order
+func (c *Cache) snapshot() snapshot {
+	now := c.clock.Now()
+	return snapshot{SavedAt: now, Items: c.savedItems(now)}
+}
+
+// savedItems lists the items live at now with their remaining TTL, oldest
+// first. A sharded cache lists one shard after another, which keeps the
+// order within each.
+func (c *Cache) savedItems(now time.Time) []entry {
+	if c.parts != nil {
+		items := make([]entry, 0)
+		for _, part := range c.parts {
+			items = append(items, part.savedItems(now)...)
+		}
+		return items
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	items := make([]entry, 0, len(c.items))
+	for e := c.order.Back(); e != nil; e = e.Prev() {
+		key := e.Value.(string)
+		item := c.items[key]
+		if item.expired(now) {
+			continue
+		}
+		saved := entry{Key: key, Value: item.load()}
+		if item.expiration != 0 {
+			saved.Expiration = time.Duration(item.expiration - now.UnixNano()).String()
+		}
+		items = append(items, saved)
+	}
+	return items
+}
+
+// restore loads the items of snap, skipping any whose TTL has elapsed since it
+// was taken, and returns how many it stored. Like Preload it does not write
+// through to the backing store.
+func (c *Cache) restore(snap snapshot) (int, error) {
+	now := c.clock.Now()
+	elapsed := now.Sub(snap.SavedAt)
+	items := make(map[string]CacheItem, len(snap.Items))
+	keys := make([]string, 0, len(snap.Items))
+	for _, saved := range snap.Items {
+		var expiration int64
+		if saved.Expiration != "" {
+			ttl, err := time.ParseDuration(saved.Expiration)
+			if err != nil {
+				return 0, err
+			}
+			ttl -= elapsed
+			if ttl <= 0 {
+				continue
+			}
+			expiration = now.Add(ttl).UnixNano()
+		}
+		items[saved.Key] = CacheItem{value: saved.Value, expiration: expiration}
+		keys = append(keys, saved.Key)
+	}
+	c.seed(keys, items)
+	return len(keys), nil
+}
+
+// seed stores items in the order of keys without writing them through to the
+// backing store, under a single write lock or one per shard
+func (c *Cache) seed(keys []string, items map[string]CacheItem) {
+	if c.parts != nil {
+		for i, group := range c.byPart(keys) {
+			if group != nil {
+				c.parts[i].seed(group, items)
+			}
+		}
+		return
+	}
+	c.lock()
+	defer c.unlock()
+	for _, key := range keys {
+		c.put(key, items[key].value, items[key].expiration)
+	}
+	c.saves = nil
+}
+
+// write every live item with its remaining TTL, in the format read by /import
+func (c *Cache) exportHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	jsonEncoder(w, r).Encode(c.snapshot())
+}
+
+// merge items written by /export into the cache, dropping the ones that have expired since
+func (c *Cache) importHandler(w http.ResponseWriter, r *http.Request) {
+	var snap snapshot
+	if err := (jsonCodec{}).Decode(r.Body, &snap); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	imported, err := c.restore(snap)
+	if err != nil {
+		writeError(w, r, "Invalid expiration duration", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	jsonEncoder(w, r).Encode(map[string]int{"imported": imported})
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// ageSnapshot moves the saved_at time of the snapshot at path back by d
+func ageSnapshot(t *testing.T, path string, d time.Duration) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		t.Fatal(err)
+	}
+	snap.SavedAt = snap.SavedAt.Add(-d)
+	if data, err = json.Marshal(snap); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	saved := NewCache()
+	defer saved.Close()
+	saved.Set("old", "a", 0)
+	saved.Set("list", []interface{}{"x", "y"}, time.Minute)
+	saved.Set("brief", "b", 5*time.Second)
+	saved.Set("new", 42, time.Hour)
+	if err := saved.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	ageSnapshot(t, path, 10*time.Second)
+	loaded := NewCache()
+	defer loaded.Close()
+	if err := loaded.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := recency(loaded), []string{"new", "list", "old"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got keys %v, want %v most recent first", got, want)
+	}
+	if got, _ := loaded.Get("list"); !reflect.DeepEqual(got, []interface{}{"x", "y"}) {
+		t.Errorf("list: got %v", got)
+	}
+	if got, _ := loaded.Get("new"); got != json.Number("42") {
+		t.Errorf("new: got %#v, want 42", got)
+	}
+	if ttl, _ := loaded.TTL("list"); ttl <= 49*time.Second || ttl > 50*time.Second {
+		t.Errorf("list: got TTL %s, want the 50s left after 10s", ttl)
+	}
+	if ttl, _ := loaded.TTL("old"); ttl != NoExpiration {
+		t.Errorf("old: got TTL %s, want none", ttl)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	from := NewCacheWithOptions(Options{SweepInterval: time.Hour})
+	defer from.Close()
+	to := NewCache()
+	defer to.Close()
+	to.Set("kept", true, 0)
+
+	from.Set("text", "1", time.Minute)
+	from.Set("forever", 2, 0)
+	from.Set("brief", 3, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	exported := serve(NewServer(from), http.MethodGet, "/export", "")
+	if exported.Code != http.StatusOK {
+		t.Fatalf("/export: status %d", exported.Code)
+	}
+	rec := serve(NewServer(to), http.MethodPost, "/import", exported.Body.String())
+	var body struct{ Imported int }
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("/import: status %d, %v", rec.Code, err)
+	}
+	if body.Imported != 2 {
+		t.Fatalf("imported %d items, want 2", body.Imported)
+	}
+	if value, _ := to.Get("text"); value != "1" {
+		t.Fatalf("text = %v", value)
+	}
+	if ttl, _ := to.TTL("text"); ttl >= time.Minute-20*time.Millisecond || ttl < time.Minute-time.Second {
+		t.Fatalf("text TTL %s, want the time left on export", ttl)
+	}
+	if ttl, _ := to.TTL("forever"); ttl != NoExpiration {
+		t.Fatalf("forever TTL %s", ttl)
+	}
+	if to.Has("brief") {
+		t.Fatal("an expired item was exported")
+	}
+	if !to.Has("kept") {
+		t.Fatal("import replaced the existing items instead of merging")
+	}
+
+	if rec := serve(NewServer(to), http.MethodPost, "/import", "{"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("/import of bad JSON: status %d, want 400", rec.Code)
+	}
+}
+
+func TestSaveFailureKeepsSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.json")
+	c := NewCache()
+	defer c.Close()
+	c.Set("a", 1, 0)
+	if err := c.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.ReadFile(path)
+
+	c.Set("bad", func() {}, 0)
+	if err := c.SaveToFile(path); err == nil {
+		t.Fatal("saved a value JSON cannot encode")
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(after, before) {
+		t.Fatalf("failed save changed the snapshot to %q", after)
+	}
+
+	// A rename onto a non-empty directory fails after the temporary file is written
+	blocked := filepath.Join(dir, "blocked")
+	if err := os.MkdirAll(filepath.Join(blocked, "child"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(blocked, []byte("{}"), 0o644); err == nil {
+		t.Fatal("writeFileAtomic replaced a directory")
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, "*.tmp-*")); len(leftovers) != 0 {
+		t.Fatalf("temporary files left behind: %v", leftovers)
+	}
+
+	fresh := NewCache()
+	defer fresh.Close()
+	if err := fresh.LoadFromFile(filepath.Join(dir, "missing.json")); err != nil || fresh.Len() != 0 {
+		t.Fatalf("missing snapshot: got %v with %d items", err, fresh.Len())
+	}
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fresh.LoadFromFile(path); err == nil {
+		t.Fatal("corrupt snapshot loaded without error")
+	}
+}
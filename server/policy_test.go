@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// fillWithHotKey stores a hot key that is read often but not lately, then
+// streams cold keys through a cache of the given policy
+func fillWithHotKey(policy EvictionPolicy) *Cache {
+	c := NewCacheWithOptions(Options{Capacity: 4, Policy: policy})
+	c.Set("hot", 0, 0)
+	for i := 0; i < 10; i++ {
+		c.Get("hot")
+	}
+	for i := 0; i < 8; i++ {
+		c.Set("cold"+strconv.Itoa(i), i, 0)
+	}
+	return c
+}
+
+func TestLFUKeepsHotKey(t *testing.T) {
+	lfu := fillWithHotKey(LFU)
+	defer lfu.Close()
+	if got, want := lfu.Keys(), []string{"cold5", "cold6", "cold7", "hot"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LFU kept %v, want hot and the newest cold keys", got)
+	}
+
+	lru := fillWithHotKey(LRU)
+	defer lru.Close()
+	if got, want := lru.Keys(), []string{"cold4", "cold5", "cold6", "cold7"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LRU kept %v, want only the newest cold keys", got)
+	}
+}
+
+func TestLFUPrefersLeastRecentOnTies(t *testing.T) {
+	c := NewCacheWithOptions(Options{Capacity: 3, Policy: LFU})
+	defer c.Close()
+	c.Set("a", 1, 0)
+	c.Set("b", 2, 0)
+	c.Set("c", 3, 0)
+	c.Get("a")
+	c.Get("b")
+	c.Get("c")
+	c.Get("a")
+	c.Set("d", 4, 0)
+	if got, want := c.Keys(), []string{"a", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("kept %v, want b evicted as the least recent of the least used", got)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiterClients bounds how many client buckets are remembered at once
+const rateLimiterClients = 64 * DefaultCapacity
+
+// rateLimiter is a per-client token bucket limiter. The buckets live in a
+// Cache keyed by client IP, so idle clients expire and the number tracked is
+// bounded by LRU eviction.
+type rateLimiter struct {
+	rate    float64 // tokens added per second
+	burst   float64 // bucket size
+	idle    time.Duration
+	buckets *Cache
+}
+
+// bucket holds the tokens left for one client
+type bucket struct {
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter allows rate requests per second per client with bursts of burst
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	buckets := newCache(Options{Capacity: rateLimiterClients})
+	close(buckets.done) // expired buckets are dropped lazily, no sweep needed
+	return &rateLimiter{
+		rate:  rate,
+		burst: float64(burst),
+		// A bucket idle this long has refilled completely and can be forgotten
+		idle:    time.Duration(float64(burst)/rate*float64(time.Second)) + time.Second,
+		buckets: buckets,
+	}
+}
+
+// allow takes a token from the bucket of client, reporting whether one was
+// available and, if not, how long until the next one is
+func (rl *rateLimiter) allow(client string) (bool, time.Duration) {
+	now := time.Now()
+	value, ok := rl.buckets.GetAndRefresh(client, rl.idle)
+	if !ok {
+		fresh := &bucket{tokens: rl.burst, last: now}
+		var err error
+		if value, _, err = rl.buckets.GetOrSet(client, fresh, rl.idle); err != nil {
+			value = fresh // a client the buckets cannot hold starts full every time
+		}
+	}
+	b := value.(*bucket)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.tokens = math.Min(rl.burst, b.tokens+now.Sub(b.last).Seconds()*rl.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rl.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// limit wraps h so requests for the given path prefixes are rejected with 429
+// once their client runs out of tokens
+func (rl *rateLimiter) limit(h http.Handler, prefixes ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range prefixes {
+			if !strings.HasPrefix(r.URL.Path, prefix) {
+				continue
+			}
+			if ok, wait := rl.allow(clientIP(r)); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				writeError(w, r, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+			break
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the IP address of the client that sent r
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
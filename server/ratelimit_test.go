@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimit(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServerWithOptions(c, ServerOptions{RateLimit: 1, RateBurst: 3})
+
+	request := func(target, addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.RemoteAddr = addr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	limited := 0
+	for i := 0; i < 10; i++ {
+		rec := request("/get?key=a", "10.0.0.1:1234")
+		if rec.Code == http.StatusTooManyRequests {
+			limited++
+			if rec.Header().Get("Retry-After") == "" {
+				t.Fatal("429 without Retry-After")
+			}
+		}
+	}
+	if limited != 7 {
+		t.Fatalf("got %d of 10 requests limited with a burst of 3, want 7", limited)
+	}
+	if rec := request("/get?key=a", "10.0.0.2:1234"); rec.Code == http.StatusTooManyRequests {
+		t.Fatal("another client was limited")
+	}
+	if rec := request("/stats", "10.0.0.1:1234"); rec.Code != http.StatusOK {
+		t.Fatalf("unlimited path: got status %d", rec.Code)
+	}
+}
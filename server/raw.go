@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io"
+	"mime"
+	"net/http"
+)
+
+// rawContentType marks request and response bodies that are the value itself,
+// stored and returned as opaque bytes without any JSON wrapping
+const rawContentType = "application/octet-stream"
+
+// isRawRequest reports whether the body of r is a raw value
+func isRawRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == rawContentType
+}
+
+// wantsRaw reports whether r asks for the value as raw bytes, with ?raw=1 or
+// by accepting only application/octet-stream
+func wantsRaw(r *http.Request) bool {
+	if r.URL.Query().Get("raw") == "1" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Accept"))
+	return err == nil && mediaType == rawContentType
+}
+
+// decodeRawSet reads a set request whose body is the raw value. The key comes
+// from key or else ?key=, and the expiration from ?expiration=.
+func (c *Cache) decodeRawSet(w http.ResponseWriter, r *http.Request, key string) (setRequest, bool) {
+	value, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeDecodeError(w, r, err)
+		return setRequest{}, false
+	}
+	if key == "" {
+		key = r.URL.Query().Get("key")
+	}
+	if err := c.CheckKey(key); err != nil {
+		writeError(w, r, err.Error(), http.StatusBadRequest)
+		return setRequest{}, false
+	}
+	expiration, err := c.parseExpiration(r.URL.Query().Get("expiration"))
+	if err != nil {
+		writeError(w, r, "Invalid expiration duration", http.StatusBadRequest)
+		return setRequest{}, false
+	}
+	return setRequest{key: key, value: value, expiration: expiration}, true
+}
+
+// writeRaw writes a []byte or string value as-is and reports whether it could.
+// Other values have no raw form.
+func writeRaw(w http.ResponseWriter, value interface{}) bool {
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return false
+	}
+	w.Header().Set("Content-Type", rawContentType)
+	w.Write(data)
+	return true
+}
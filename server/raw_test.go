@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRawRoundTrip(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+	data := []byte{0x00, 0xff, 0x10, '\n', 0x80}
+
+	req := httptest.NewRequest(http.MethodPut, "/cache/blob?expiration=1m", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("raw set: status %d, body %q", rec.Code, rec.Body.String())
+	}
+	if ttl, _ := c.TTL("blob"); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("raw set ignored ?expiration=: TTL %s", ttl)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/cache/blob", nil)
+	req.Header.Set("Accept", "application/octet-stream")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if ct := rec.Header().Get("Content-Type"); ct != "application/octet-stream" {
+		t.Fatalf("Content-Type %q", ct)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), data) {
+		t.Fatalf("got % x, want % x", rec.Body.Bytes(), data)
+	}
+
+	rec = serve(h, http.MethodGet, "/get?key=blob&raw=1", "")
+	if !bytes.Equal(rec.Body.Bytes(), data) {
+		t.Fatalf("?raw=1: got % x", rec.Body.Bytes())
+	}
+
+	// A value with no raw form falls back to JSON
+	c.Set("number", 7, 0)
+	rec = serve(h, http.MethodGet, "/get?key=number&raw=1", "")
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("raw read of a number: Content-Type %q", ct)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var uuidV4 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestWithRequestID(t *testing.T) {
+	var seen string
+	h := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+	}))
+
+	tests := []struct {
+		name, sent string
+		echoed     bool
+	}{
+		{"client ID", "trace-42", true},
+		{"none", "", false},
+		{"too long", strings.Repeat("x", maxRequestIDLength+1), false},
+		{"unprintable", "bad id", false},
+	}
+	generated := make(map[string]bool)
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.sent != "" {
+			req.Header.Set(requestIDHeader, tt.sent)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		id := rec.Header().Get(requestIDHeader)
+		if id != seen {
+			t.Errorf("%s: response has ID %q but the context had %q", tt.name, id, seen)
+		}
+		switch {
+		case tt.echoed && id != tt.sent:
+			t.Errorf("%s: got %q, want the client ID echoed", tt.name, id)
+		case !tt.echoed && !uuidV4.MatchString(id):
+			t.Errorf("%s: generated %q, want a version 4 UUID", tt.name, id)
+		case !tt.echoed:
+			generated[id] = true
+		}
+	}
+	if len(generated) != 3 {
+		t.Fatalf("generated IDs repeat: %v", generated)
+	}
+	if id := RequestID(httptest.NewRequest(http.MethodGet, "/", nil).Context()); id != "" {
+		t.Fatalf("request outside the middleware has ID %q", id)
+	}
+}
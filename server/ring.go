@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultVirtualNodes is the number of points each shard gets on a HashRing
+// created with zero
+const DefaultVirtualNodes = 128
+
+// HashRing assigns keys to named shards by consistent hashing. Every shard is
+// placed at several points on a ring of hashes and a key belongs to the first
+// point at or after its own hash, so adding or removing a shard only moves
+// the keys next to its points, about 1/n of them, rather than nearly all.
+// It is not safe for concurrent use.
+type HashRing struct {
+	replicas int
+	names    []string          // shards on the ring, sorted
+	points   []uint64          // hashes of every virtual node, sorted
+	owners   map[uint64]string // shard placed at each point
+}
+
+// NewHashRing creates an empty ring placing each shard at replicas points.
+// It panics if replicas is negative; zero selects DefaultVirtualNodes.
+func NewHashRing(replicas int) *HashRing {
+	if replicas < 0 {
+		panic(fmt.Sprintf("virtual node count must be positive, got %d", replicas))
+	}
+	if replicas == 0 {
+		replicas = DefaultVirtualNodes
+	}
+	return &HashRing{replicas: replicas, owners: make(map[uint64]string)}
+}
+
+// ringHash hashes a key or virtual node name onto the ring. FNV alone barely
+// changes its high bits between names that differ in the last byte, such as
+// consecutive virtual nodes, so the result goes through the murmur3 finalizer
+// to spread the points evenly.
+func ringHash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// AddShard places name on the ring, reporting false if it was already there
+func (r *HashRing) AddShard(name string) bool {
+	if r.Has(name) {
+		return false
+	}
+	r.names = append(r.names, name)
+	sort.Strings(r.names)
+	r.rebuild()
+	return true
+}
+
+// RemoveShard takes name off the ring, reporting false if it was not there
+func (r *HashRing) RemoveShard(name string) bool {
+	i := sort.SearchStrings(r.names, name)
+	if i == len(r.names) || r.names[i] != name {
+		return false
+	}
+	r.names = append(r.names[:i], r.names[i+1:]...)
+	r.rebuild()
+	return true
+}
+
+// Has reports whether name is on the ring
+func (r *HashRing) Has(name string) bool {
+	i := sort.SearchStrings(r.names, name)
+	return i < len(r.names) && r.names[i] == name
+}
+
+// Shards returns the names on the ring in sorted order
+func (r *HashRing) Shards() []string {
+	return append([]string(nil), r.names...)
+}
+
+// rebuild recomputes the points from the shard names. The names are visited
+// in sorted order so that the rare hash collision always goes to the same
+// shard, whatever order the shards were added in.
+func (r *HashRing) rebuild() {
+	r.points = r.points[:0]
+	clear(r.owners)
+	for _, name := range r.names {
+		for i := 0; i < r.replicas; i++ {
+			point := ringHash(name + "#" + strconv.Itoa(i))
+			if _, taken := r.owners[point]; taken {
+				continue
+			}
+			r.owners[point] = name
+			r.points = append(r.points, point)
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+}
+
+// Shard returns the name of the shard owning key, or "" if the ring is empty
+func (r *HashRing) Shard(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	hash := ringHash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+// RingCache spreads keys over named caches with a HashRing. Unlike the shards
+// of a cache created with Options.Shards, they can be added and removed while
+// it is in use, and only the keys whose owner changes are moved.
+type RingCache struct {
+	opts   Options
+	mutex  sync.RWMutex // guards ring and shards, held for writing while keys move
+	ring   *HashRing
+	shards map[string]*Cache
+}
+
+// NewRingCache creates a cache with one shard per name, each created from
+// opts, so the capacity and budgets in opts apply to every shard on its own.
+// It panics if no names are given.
+func NewRingCache(opts Options, names ...string) *RingCache {
+	if len(names) == 0 {
+		panic("ring cache needs at least one shard")
+	}
+	rc := &RingCache{opts: opts, ring: NewHashRing(0), shards: make(map[string]*Cache)}
+	for _, name := range names {
+		rc.AddShard(name)
+	}
+	return rc
+}
+
+// AddShard creates the shard name and moves to it the keys it now owns,
+// reporting false if the shard already exists. Other operations wait until
+// the keys have moved.
+func (rc *RingCache) AddShard(name string) bool {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	if !rc.ring.AddShard(name) {
+		return false
+	}
+	rc.shards[name] = NewCacheWithOptions(rc.opts)
+	for from, shard := range rc.shards {
+		if from != name {
+			rc.rehome(shard)
+		}
+	}
+	return true
+}
+
+// RemoveShard moves the keys of shard name to the shards that now own them
+// and closes it. It reports false if the shard does not exist or is the last
+// one, which has nowhere to move its keys to.
+func (rc *RingCache) RemoveShard(name string) bool {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	shard, found := rc.shards[name]
+	if !found || len(rc.shards) == 1 {
+		return false
+	}
+	rc.ring.RemoveShard(name)
+	delete(rc.shards, name)
+	rc.rehome(shard)
+	shard.Close()
+	return true
+}
+
+// rehome moves the live keys of shard that the ring assigns elsewhere to
+// their owner, keeping their values and expirations. Tags and costs are not
+// carried over. The caller must hold rc.mutex for writing.
+func (rc *RingCache) rehome(shard *Cache) {
+	moved := shard.extract(func(key string) bool {
+		return rc.shards[rc.ring.Shard(key)] != shard
+	})
+	for _, m := range moved {
+		owner := rc.shards[rc.ring.Shard(m.key)].owner(m.key)
+		owner.lock()
+		owner.put(m.key, m.value, m.expiration)
+		owner.unlock()
+	}
+}
+
+// extractedItem is a key removed from one cache to be stored in another
+type extractedItem struct {
+	key        string
+	value      interface{}
+	expiration int64
+}
+
+// extract removes the live items whose key satisfies move and returns them,
+// oldest first so that storing them in order keeps their relative recency
+func (c *Cache) extract(move func(key string) bool) []extractedItem {
+	if c.parts != nil {
+		var moved []extractedItem
+		for _, part := range c.parts {
+			moved = append(moved, part.extract(move)...)
+		}
+		return moved
+	}
+	c.lock()
+	defer c.unlock()
+	now := c.clock.Now()
+	var moved []extractedItem
+	for e := c.order.Back(); e != nil; {
+		prev := e.Prev()
+		key := e.Value.(string)
+		item := c.items[key]
+		if !item.expired(now) && move(key) {
+			moved = append(moved, extractedItem{key: key, value: item.load(), expiration: item.expiration})
+			c.removeItem(key, item)
+		}
+		e = prev
+	}
+	return moved
+}
+
+// Set stores the value in the shard owning key
+func (rc *RingCache) Set(key string, value interface{}, expiration time.Duration) {
+	rc.mutex.RLock()
+	defer rc.mutex.RUnlock()
+	rc.shards[rc.ring.Shard(key)].Set(key, value, expiration)
+}
+
+// Get retrieves the value from the shard owning key
+func (rc *RingCache) Get(key string) (interface{}, bool) {
+	rc.mutex.RLock()
+	defer rc.mutex.RUnlock()
+	return rc.shards[rc.ring.Shard(key)].Get(key)
+}
+
+// Delete removes key from the shard owning it and reports whether it was present
+func (rc *RingCache) Delete(key string) bool {
+	rc.mutex.RLock()
+	defer rc.mutex.RUnlock()
+	return rc.shards[rc.ring.Shard(key)].Delete(key)
+}
+
+// Len returns the number of live items across all shards
+func (rc *RingCache) Len() int {
+	rc.mutex.RLock()
+	defer rc.mutex.RUnlock()
+	total := 0
+	for _, shard := range rc.shards {
+		total += shard.Len()
+	}
+	return total
+}
+
+// Close stops the eviction process of every shard and waits for them to exit
+func (rc *RingCache) Close() {
+	rc.mutex.RLock()
+	defer rc.mutex.RUnlock()
+	for _, shard := range rc.shards {
+		shard.Close()
+	}
+}
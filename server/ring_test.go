@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHashRingMovesFewKeys(t *testing.T) {
+	ring := NewHashRing(0)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		ring.AddShard(name)
+	}
+	const keys = 10000
+	before := make(map[string]string, keys)
+	counts := make(map[string]int)
+	for i := 0; i < keys; i++ {
+		key := "key" + strconv.Itoa(i)
+		before[key] = ring.Shard(key)
+		counts[before[key]]++
+	}
+	for name, n := range counts {
+		if n < keys/8 || n > keys/2 {
+			t.Errorf("shard %s owns %d of %d keys", name, n, keys)
+		}
+	}
+
+	if !ring.AddShard("e") || ring.AddShard("e") {
+		t.Fatal("AddShard did not report whether e was new")
+	}
+	moved := 0
+	for key, owner := range before {
+		if now := ring.Shard(key); now != owner {
+			if now != "e" {
+				t.Fatalf("%s moved from %s to %s rather than to the new shard", key, owner, now)
+			}
+			moved++
+		}
+	}
+	// About a fifth of the keys should move to the fifth shard
+	if moved < keys/10 || moved > keys*3/10 {
+		t.Fatalf("%d of %d keys moved", moved, keys)
+	}
+
+	ring.RemoveShard("e")
+	for key, owner := range before {
+		if ring.Shard(key) != owner {
+			t.Fatalf("%s did not return to %s after e was removed", key, owner)
+		}
+	}
+	if ring.RemoveShard("e") || NewHashRing(0).Shard("k") != "" {
+		t.Fatal("an absent shard was removed or an empty ring owned a key")
+	}
+}
+
+func TestRingCacheRebalances(t *testing.T) {
+	rc := NewRingCache(Options{Capacity: 1000}, "a", "b")
+	defer rc.Close()
+	for i := 0; i < 500; i++ {
+		rc.Set(strconv.Itoa(i), i, time.Hour)
+	}
+
+	if !rc.AddShard("c") || rc.AddShard("c") {
+		t.Fatal("AddShard did not report whether c was new")
+	}
+	if rc.shards["c"].Len() == 0 {
+		t.Fatal("no keys moved to the new shard")
+	}
+	if !rc.RemoveShard("a") || rc.RemoveShard("a") {
+		t.Fatal("RemoveShard did not report whether a existed")
+	}
+	if rc.Len() != 500 {
+		t.Fatalf("%d keys after rebalancing, want 500", rc.Len())
+	}
+	for i := 0; i < 500; i++ {
+		key := strconv.Itoa(i)
+		if value, ok := rc.Get(key); !ok || value != i {
+			t.Fatalf("%s: got %v, %v", key, value, ok)
+		}
+		if ttl, _ := rc.shards[rc.ring.Shard(key)].TTL(key); ttl <= 59*time.Minute {
+			t.Fatalf("%s lost its expiration when moved: %s", key, ttl)
+		}
+	}
+
+	rc.RemoveShard("b")
+	if rc.RemoveShard("c") {
+		t.Fatal("the last shard was removed")
+	}
+	if rc.Len() != 500 {
+		t.Fatalf("%d keys on the last shard, want 500", rc.Len())
+	}
+}
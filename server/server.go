@@ -0,0 +1,495 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServerOptions configures the middleware wrapped around the HTTP API.
+// Zero values leave the corresponding middleware disabled, except for the body size limit.
+type ServerOptions struct {
+	RateLimit float64 // requests per second allowed per client IP on /get and /set
+	RateBurst int     // requests a client may make at once, at least 1
+
+	CORS       bool   // answer preflight requests and add CORS headers to every response
+	CORSOrigin string // allowed origin when CORS is on, "*" if empty
+
+	APIKey string // key every request except health probes must present, none required if empty
+
+	MaxBodyBytes int64 // largest request body read, DefaultMaxBodyBytes if zero and unlimited if negative
+
+	MaxInFlight int // requests handled at once before answering 503, unlimited if zero; /events streams are not counted
+}
+
+// NewServerWithOptions is NewServer wrapped in the middleware selected by opts
+func NewServerWithOptions(c *Cache, opts ServerOptions) http.Handler {
+	return wrapHandler(NewServer(c), opts)
+}
+
+// wrapHandler applies the middleware selected by opts to h, and gives every request an ID
+func wrapHandler(h http.Handler, opts ServerOptions) http.Handler {
+	switch {
+	case opts.MaxBodyBytes == 0:
+		h = limitBody(h, DefaultMaxBodyBytes)
+	case opts.MaxBodyBytes > 0:
+		h = limitBody(h, opts.MaxBodyBytes)
+	}
+	h = limitInFlight(h, opts.MaxInFlight, "/events")
+	if opts.RateLimit > 0 {
+		h = newRateLimiter(opts.RateLimit, opts.RateBurst).limit(h, "/get", "/set", "/cache/")
+	}
+	if opts.APIKey != "" {
+		h = requireAPIKey(h, opts.APIKey, "/healthz", "/readyz")
+	}
+	if opts.CORS {
+		h = allowCORS(h, opts.CORSOrigin)
+	}
+	return withRequestID(h)
+}
+
+// NewServer registers the cache API on a dedicated mux and returns it, so the
+// HTTP layer can be served by main or exercised with httptest
+func NewServer(c *Cache) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/get", allowMethods(c.getHandler, http.MethodGet))
+	mux.HandleFunc("/getx", allowMethods(c.getxHandler, http.MethodGet))
+	mux.HandleFunc("/set", allowMethods(c.setHandler, http.MethodGet, http.MethodPost, http.MethodPut))
+	mux.HandleFunc("/delete", allowMethods(c.deleteHandler, http.MethodDelete))
+	mux.HandleFunc("/add", allowMethods(c.addHandler, http.MethodPost, http.MethodPut))
+	mux.HandleFunc("/replace", allowMethods(c.replaceHandler, http.MethodPost, http.MethodPut))
+	mux.HandleFunc("/cache", allowMethods(c.hasHandler, http.MethodHead))
+	mux.HandleFunc("/cache/", c.cacheHandler)
+	mux.HandleFunc("/stats", allowMethods(c.statsHandler, http.MethodGet))
+	mux.HandleFunc("/stats/history", allowMethods(c.statsHistoryHandler, http.MethodGet))
+	mux.HandleFunc("/idle", allowMethods(c.idleHandler, http.MethodGet))
+	mux.HandleFunc("/ttl", allowMethods(c.ttlHandler, http.MethodGet))
+	mux.HandleFunc("/mset", allowMethods(c.msetHandler, http.MethodPost))
+	mux.HandleFunc("/mget", allowMethods(c.mgetHandler, http.MethodPost))
+	mux.HandleFunc("/incr", allowMethods(c.incrHandler, http.MethodPost))
+	mux.HandleFunc("/keys", allowMethods(c.keysHandler, http.MethodGet))
+	mux.HandleFunc("/dump", allowMethods(c.dumpHandler, http.MethodGet))
+	mux.HandleFunc("/flush", allowMethods(c.flushHandler, http.MethodPost))
+	mux.HandleFunc("/flush-expired", allowMethods(c.flushExpiredHandler, http.MethodPost))
+	mux.HandleFunc("/touch", allowMethods(c.touchHandler, http.MethodPost))
+	mux.HandleFunc("/touch-prefix", allowMethods(c.touchPrefixHandler, http.MethodPost))
+	mux.HandleFunc("/delete-prefix", allowMethods(c.deletePrefixHandler, http.MethodPost, http.MethodDelete))
+	mux.HandleFunc("/export", allowMethods(c.exportHandler, http.MethodGet))
+	mux.HandleFunc("/import", allowMethods(c.importHandler, http.MethodPost))
+	mux.HandleFunc("/events", allowMethods(c.eventsHandler, http.MethodGet))
+	mux.Handle("/metrics", c.metricsHandler())
+	return c.logErrors(mux)
+}
+
+// writeError writes a JSON error body such as {"error":"message","code":404} with the given status.
+// The body also carries the request ID when the request has one, and is
+// indented like every other response when r asks for ?pretty=1.
+func writeError(w http.ResponseWriter, r *http.Request, message string, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	jsonEncoder(w, r).Encode(struct {
+		Error     string `json:"error"`
+		Code      int    `json:"code"`
+		RequestID string `json:"request_id,omitempty"`
+	}{message, code, w.Header().Get(requestIDHeader)})
+}
+
+// allowMethods rejects requests whose method is not one of methods with 405
+func allowMethods(h http.HandlerFunc, methods ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, method := range methods {
+			if r.Method == method {
+				h(w, r)
+				return
+			}
+		}
+		methodNotAllowed(w, r, methods...)
+	}
+}
+
+// methodNotAllowed writes a 405 response listing the allowed methods
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, methods ...string) {
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+// serve GET, PUT and DELETE for the key at /cache/{key}
+func (c *Cache) cacheHandler(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/cache/")
+	if key == "" {
+		writeError(w, r, "Key is required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		c.serveGet(w, r, key)
+	case http.MethodPut:
+		c.serveSet(w, r, key)
+	case http.MethodDelete:
+		c.serveDelete(w, r, key)
+	case http.MethodHead:
+		c.serveHas(w, key)
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// check whether the key is live, answering with a status code and no body
+func (c *Cache) hasHandler(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.serveHas(w, key)
+}
+
+// serveHas answers 200 if key is live and 404 otherwise
+func (c *Cache) serveHas(w http.ResponseWriter, key string) {
+	if !c.Has(key) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Get the value
+func (c *Cache) getHandler(w http.ResponseWriter, r *http.Request) {
+
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeError(w, r, "Key is required", http.StatusBadRequest)
+		return
+	}
+
+	c.serveGet(w, r, key)
+}
+
+// serveGet writes the value stored under key in the format asked for by the
+// Accept header, or as raw bytes if asked for and the value has a raw form,
+// answering 410 if the key has expired and 404 if it is unknown
+func (c *Cache) serveGet(w http.ResponseWriter, r *http.Request, key string) {
+	value, expiration, err := c.fetch(key)
+	switch {
+	case errors.Is(err, ErrExpired):
+		writeError(w, r, "Key expired", http.StatusGone)
+		return
+	case err != nil:
+		writeError(w, r, "Key not found", http.StatusNotFound)
+		return
+	}
+	if c.httpCache && expiration != 0 {
+		setCacheHeaders(w, time.Unix(0, expiration), c.clock.Now())
+	}
+
+	if wantsRaw(r) && writeRaw(w, value) {
+		return
+	}
+	codec := responseCodec(r)
+	w.Header().Set("Content-Type", codec.ContentType())
+	if _, isJSON := codec.(jsonCodec); isJSON {
+		jsonEncoder(w, r).Encode(value)
+		return
+	}
+	codec.Encode(w, value)
+}
+
+// setCacheHeaders tells HTTP caches to keep the response until expiresAt
+func setCacheHeaders(w http.ResponseWriter, expiresAt, now time.Time) {
+	maxAge := max(int64(expiresAt.Sub(now)/time.Second), 0)
+	w.Header().Set("Cache-Control", "max-age="+strconv.FormatInt(maxAge, 10))
+	w.Header().Set("Expires", expiresAt.UTC().Format(http.TimeFormat))
+}
+
+// get the value along with its expiration time, left out for keys that never expire
+func (c *Cache) getxHandler(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeError(w, r, "Key is required", http.StatusBadRequest)
+		return
+	}
+
+	value, meta, ok := c.GetWithMeta(key)
+	if !ok {
+		writeError(w, r, "Key not found or expired", http.StatusNotFound)
+		return
+	}
+
+	result := struct {
+		Value     interface{} `json:"value"`
+		ExpiresAt *time.Time  `json:"expires_at,omitempty"`
+		CreatedAt time.Time   `json:"created_at"`
+		UpdatedAt time.Time   `json:"updated_at"`
+	}{Value: value, CreatedAt: meta.CreatedAt, UpdatedAt: meta.UpdatedAt}
+	if !meta.ExpiresAt.IsZero() {
+		result.ExpiresAt = &meta.ExpiresAt
+	}
+	w.Header().Set("Content-Type", "application/json")
+	jsonEncoder(w, r).Encode(result)
+}
+
+// parseExpiration parses the expiration field of a request. An omitted
+// expiration uses the cache default TTL, and an explicit zero such as "0s"
+// stores the key permanently, never expired on arrival.
+func (c *Cache) parseExpiration(raw string) (time.Duration, error) {
+	if raw == "" {
+		return c.DefaultTTL(), nil
+	}
+	return time.ParseDuration(raw)
+}
+
+// set the cache data
+func (c *Cache) setHandler(w http.ResponseWriter, r *http.Request) {
+	c.serveSet(w, r, "")
+}
+
+// serveSet stores the value from the JSON body. A non-empty key overrides
+// the key given in the body.
+func (c *Cache) serveSet(w http.ResponseWriter, r *http.Request, key string) {
+	req, ok := c.decodeSet(w, r, key)
+	if !ok {
+		return
+	}
+	var err error
+	if !req.deadline.IsZero() {
+		err = c.TrySetWithDeadline(req.key, req.value, req.deadline)
+	} else {
+		err = c.TrySet(req.key, req.value, req.expiration)
+	}
+	if err != nil {
+		writeSetError(w, r, err)
+		return
+	}
+	writeSetConfirmation(w, r, req)
+}
+
+// writeSetError answers a set the cache refused, with 507 if it is full
+func writeSetError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrCacheFull) {
+		writeError(w, r, "Cache is full", http.StatusInsufficientStorage)
+		return
+	}
+	writeError(w, r, err.Error(), http.StatusBadRequest)
+}
+
+// setRequest is a decoded set request body
+type setRequest struct {
+	key        string
+	value      interface{}
+	expiration time.Duration
+	deadline   time.Time // absolute expiration from expires_at, expiration then holds the time left
+}
+
+// decodeSet reads a set request from the body, in the format given by its
+// Content-Type (see decodeRawSet for raw values and formSetValues for query
+// parameters and forms), overriding its key with key
+// if that is not empty. The body may give either a relative expiration or an
+// RFC 3339 expires_at in the future. It writes a 400, or a 413 for an
+// oversized body or value, and returns false if the body, key or value is invalid.
+func (c *Cache) decodeSet(w http.ResponseWriter, r *http.Request, key string) (setRequest, bool) {
+	req, ok := c.decodeSetRequest(w, r, key)
+	if !ok {
+		return setRequest{}, false
+	}
+	if err := c.CheckValueSize(req.value); err != nil {
+		writeError(w, r, err.Error(), http.StatusRequestEntityTooLarge)
+		return setRequest{}, false
+	}
+	return req, true
+}
+
+// decodeSetRequest is decodeSet without the value size check
+func (c *Cache) decodeSetRequest(w http.ResponseWriter, r *http.Request, key string) (setRequest, bool) {
+	if isRawRequest(r) {
+		return c.decodeRawSet(w, r, key)
+	}
+	values, isForm, err := formSetValues(r)
+	if err != nil {
+		writeDecodeError(w, r, err)
+		return setRequest{}, false
+	}
+	if isForm {
+		return c.decodeFormSet(w, r, values, key)
+	}
+	var data struct {
+		Key        string      `json:"key"`
+		Value      interface{} `json:"value"`
+		Expiration string      `json:"expiration"`
+		ExpiresAt  string      `json:"expires_at"`
+	}
+	if err := requestCodec(r).Decode(r.Body, &data); err != nil {
+		writeDecodeError(w, r, err)
+		return setRequest{}, false
+	}
+	if key != "" {
+		data.Key = key
+	}
+	if err := c.CheckKey(data.Key); err != nil {
+		writeError(w, r, err.Error(), http.StatusBadRequest)
+		return setRequest{}, false
+	}
+	if data.ExpiresAt != "" {
+		if data.Expiration != "" {
+			writeError(w, r, "Only one of expiration and expires_at may be given", http.StatusBadRequest)
+			return setRequest{}, false
+		}
+		deadline, err := time.Parse(time.RFC3339Nano, data.ExpiresAt)
+		if err != nil {
+			writeError(w, r, "Invalid expires_at timestamp", http.StatusBadRequest)
+			return setRequest{}, false
+		}
+		remaining := deadline.Sub(c.clock.Now())
+		if remaining <= 0 {
+			writeError(w, r, "expires_at is in the past", http.StatusBadRequest)
+			return setRequest{}, false
+		}
+		return setRequest{key: data.Key, value: data.Value, expiration: remaining, deadline: deadline}, true
+	}
+	expiration, err := c.parseExpiration(data.Expiration)
+	if err != nil {
+		writeError(w, r, "Invalid expiration duration", http.StatusBadRequest)
+		return setRequest{}, false
+	}
+	return setRequest{key: data.Key, value: data.Value, expiration: expiration}, true
+}
+
+// writeSetConfirmation answers a successful set with 201 and the stored key
+func writeSetConfirmation(w http.ResponseWriter, r *http.Request, req setRequest) {
+	// Keys stored without expiration leave the field out
+	confirmation := struct {
+		Key        string `json:"key"`
+		Expiration string `json:"expiration,omitempty"`
+	}{Key: req.key}
+	if req.expiration > 0 {
+		confirmation.Expiration = req.expiration.String()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	jsonEncoder(w, r).Encode(confirmation)
+}
+
+// store the value only if the key is not already live
+func (c *Cache) addHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := c.decodeSet(w, r, "")
+	if !ok {
+		return
+	}
+	if !c.SetIfAbsent(req.key, req.value, req.expiration) {
+		if !c.Has(req.key) {
+			writeSetError(w, r, ErrCacheFull)
+			return
+		}
+		writeError(w, r, "Key already exists", http.StatusConflict)
+		return
+	}
+	writeSetConfirmation(w, r, req)
+}
+
+// store the value only if the key is already live
+func (c *Cache) replaceHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := c.decodeSet(w, r, "")
+	if !ok {
+		return
+	}
+	if !c.SetIfPresent(req.key, req.value, req.expiration) {
+		writeError(w, r, "Key not found or expired", http.StatusNotFound)
+		return
+	}
+	writeSetConfirmation(w, r, req)
+}
+
+// delete the key
+func (c *Cache) deleteHandler(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeError(w, r, "Key is required", http.StatusBadRequest)
+		return
+	}
+
+	c.serveDelete(w, r, key)
+}
+
+// serveDelete removes key and reports the outcome as JSON
+func (c *Cache) serveDelete(w http.ResponseWriter, r *http.Request, key string) {
+	if !c.Delete(key) {
+		writeError(w, r, "Key not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	jsonEncoder(w, r).Encode(map[string]bool{"deleted": true})
+}
+
+// remove every key
+func (c *Cache) flushHandler(w http.ResponseWriter, r *http.Request) {
+	c.Clear()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sweep expired keys now rather than on the next tick, reporting how many were evicted
+func (c *Cache) flushExpiredHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	jsonEncoder(w, r).Encode(map[string]int{"evicted": c.evictExpiredItems()})
+}
+
+// serverStats is the /stats body: the cache counters along with the load on
+// the process, which it shares with every other cache it serves
+type serverStats struct {
+	Stats
+	InFlight int64 `json:"in_flight"` // HTTP requests being handled by this process
+	Streams  int64 `json:"streams"`   // /events streams open in this process
+}
+
+// report the cache counters
+func (c *Cache) statsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	jsonEncoder(w, r).Encode(serverStats{Stats: c.Stats(), InFlight: inFlight.Load(), Streams: openStreams.Load()})
+}
+
+// give the key a new expiration
+func (c *Cache) touchHandler(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeError(w, r, "Key is required", http.StatusBadRequest)
+		return
+	}
+	ttl, err := time.ParseDuration(r.URL.Query().Get("ttl"))
+	if err != nil {
+		writeError(w, r, "Invalid ttl duration", http.StatusBadRequest)
+		return
+	}
+
+	if !c.UpdateTTL(key, ttl) {
+		writeError(w, r, "Key not found or expired", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	jsonEncoder(w, r).Encode(map[string]bool{"touched": true})
+}
+
+// report the time left before the key expires
+func (c *Cache) ttlHandler(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeError(w, r, "Key is required", http.StatusBadRequest)
+		return
+	}
+
+	ttl, ok := c.TTL(key)
+	if !ok {
+		writeError(w, r, "Key not found or expired", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	seconds := int64(ttl / time.Second)
+	if ttl == NoExpiration {
+		seconds = -1
+	}
+	jsonEncoder(w, r).Encode(map[string]int64{"ttl_seconds": seconds})
+}
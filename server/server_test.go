@@ -0,0 +1,447 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve sends one request to h and returns the recorded response.
+func serve(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeError decodes the JSON error body of rec
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) (message string, code int) {
+	t.Helper()
+	var body struct {
+		Error string `json:"error"`
+		Code  int    `json:"code"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("error body %q is not JSON: %v", rec.Body.String(), err)
+	}
+	return body.Error, body.Code
+}
+
+func TestPrettyResponses(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+	c.Set("a", map[string]interface{}{"b": 1}, 0)
+
+	plain := serve(h, http.MethodGet, "/get?key=a", "")
+	pretty := serve(h, http.MethodGet, "/get?key=a&pretty=1", "")
+	if plain.Body.String() != `{"b":1}`+"\n" {
+		t.Errorf("plain body %q", plain.Body.String())
+	}
+	if pretty.Body.String() != "{\n  \"b\": 1\n}\n" {
+		t.Errorf("pretty body %q is not indented", pretty.Body.String())
+	}
+	if rec := serve(h, http.MethodGet, "/stats?pretty=1", ""); !strings.Contains(rec.Body.String(), "\n  \"hits\": ") {
+		t.Errorf("pretty /stats %q is not indented", rec.Body.String())
+	}
+}
+
+func TestDeleteHandler(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+	c.Set("a", 1, time.Minute)
+
+	rec := serve(h, http.MethodDelete, "/delete?key=a", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "{\"deleted\":true}\n" {
+		t.Fatalf("got status %d and body %q", rec.Code, rec.Body.String())
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("key survived /delete")
+	}
+	if rec := serve(h, http.MethodDelete, "/delete?key=a", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("missing key: got status %d, want 404", rec.Code)
+	}
+	if rec := serve(h, http.MethodDelete, "/delete", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("no key: got status %d, want 400", rec.Code)
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	c := NewCacheWithCapacity(1)
+	defer c.Close()
+	c.Set("a", 1, time.Minute)
+	c.Get("a")
+	c.Get("missing")
+	c.Set("b", 2, time.Minute)
+
+	rec := serve(NewServer(c), http.MethodGet, "/stats", "")
+	var stats Stats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Evictions != 1 || stats.Size != 1 {
+		t.Fatalf("got %+v, want 1 hit, 1 miss, 1 eviction and 1 key", stats)
+	}
+}
+
+func TestTTLHandler(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+	c.Set("fresh", 1, time.Minute)
+
+	rec := serve(h, http.MethodGet, "/ttl?key=fresh", "")
+	var body struct {
+		TTLSeconds int64 `json:"ttl_seconds"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || body.TTLSeconds < 58 || body.TTLSeconds > 60 {
+		t.Errorf("fresh: got status %d and %d seconds", rec.Code, body.TTLSeconds)
+	}
+	c.Set("forever", 2, 0)
+	if rec := serve(h, http.MethodGet, "/ttl?key=forever", ""); strings.TrimSpace(rec.Body.String()) != `{"ttl_seconds":-1}` {
+		t.Errorf("forever: got body %q", rec.Body.String())
+	}
+	if rec := serve(h, http.MethodGet, "/ttl?key=missing", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("missing: got status %d, want 404", rec.Code)
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+
+	for _, tc := range []struct {
+		method, target, allow string
+	}{
+		{http.MethodGet, "/delete?key=a", "DELETE"},
+		{http.MethodPost, "/get?key=a", "GET"},
+		{http.MethodDelete, "/set", "GET, POST, PUT"},
+		{http.MethodPost, "/cache/a", "GET, HEAD, PUT, DELETE"},
+	} {
+		rec := serve(h, tc.method, tc.target, "")
+		if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != tc.allow {
+			t.Errorf("%s %s: got status %d and Allow %q, want 405 and %q", tc.method, tc.target, rec.Code, rec.Header().Get("Allow"), tc.allow)
+		}
+	}
+}
+
+func TestCacheResource(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+
+	if rec := serve(h, http.MethodPut, "/cache/greeting", `{"value":"hello"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("PUT: got status %d, body %q", rec.Code, rec.Body.String())
+	}
+	if rec := serve(h, http.MethodGet, "/cache/greeting", ""); rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `"hello"` {
+		t.Fatalf("GET: got status %d, body %q", rec.Code, rec.Body.String())
+	}
+	if rec := serve(h, http.MethodDelete, "/cache/greeting", ""); rec.Code != http.StatusOK {
+		t.Fatalf("DELETE: got status %d", rec.Code)
+	}
+	if rec := serve(h, http.MethodGet, "/cache/greeting", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("GET after DELETE: got status %d, want 404", rec.Code)
+	}
+}
+
+func TestErrorsAreJSON(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+
+	for _, tc := range []struct {
+		method, target, body string
+		code                 int
+		message              string
+	}{
+		{http.MethodGet, "/get?key=missing", "", http.StatusNotFound, "Key not found"},
+		{http.MethodGet, "/get", "", http.StatusBadRequest, "Key is required"},
+		{http.MethodPost, "/set", `{"key":"a","value":1,"expiration":"soon"}`, http.StatusBadRequest, "Invalid expiration duration"},
+		{http.MethodGet, "/delete?key=a", "", http.StatusMethodNotAllowed, "Method not allowed"},
+	} {
+		rec := serve(h, tc.method, tc.target, tc.body)
+		if rec.Code != tc.code || rec.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s %s: got status %d and type %q", tc.method, tc.target, rec.Code, rec.Header().Get("Content-Type"))
+			continue
+		}
+		if message, code := decodeError(t, rec); message != tc.message || code != tc.code {
+			t.Errorf("%s %s: got %q and %d, want %q and %d", tc.method, tc.target, message, code, tc.message, tc.code)
+		}
+	}
+}
+
+func TestFlushHandler(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	c.Set("a", 1, 0)
+	if rec := serve(NewServer(c), http.MethodPost, "/flush", ""); rec.Code != http.StatusNoContent || c.Len() != 0 {
+		t.Fatalf("got status %d with %d keys left", rec.Code, c.Len())
+	}
+}
+
+func TestSetUsesDefaultTTL(t *testing.T) {
+	c := NewCacheWithOptions(Options{DefaultTTL: time.Minute})
+	defer c.Close()
+	h := NewServer(c)
+
+	serve(h, http.MethodPost, "/set", `{"key":"default","value":1}`)
+	serve(h, http.MethodPost, "/set", `{"key":"explicit","value":1,"expiration":"1h"}`)
+	serve(h, http.MethodPost, "/set", `{"key":"permanent","value":1,"expiration":"0s"}`)
+
+	if ttl, _ := c.TTL("default"); ttl <= 59*time.Second || ttl > time.Minute {
+		t.Errorf("omitted expiration: got TTL %s, want the 1m default", ttl)
+	}
+	if ttl, _ := c.TTL("explicit"); ttl <= 59*time.Minute {
+		t.Errorf("explicit expiration: got TTL %s, want 1h", ttl)
+	}
+	if ttl, _ := c.TTL("permanent"); ttl != NoExpiration {
+		t.Errorf("zero expiration: got TTL %s, want none", ttl)
+	}
+}
+
+func TestSetConfirmation(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+
+	for _, tc := range []struct{ body, want string }{
+		{`{"key":"n","value":42,"expiration":"30s"}`, `{"key":"n","expiration":"30s"}`},
+		{`{"key":"p","value":42}`, `{"key":"p"}`},
+	} {
+		rec := serve(h, http.MethodPost, "/set", tc.body)
+		if rec.Code != http.StatusCreated || strings.TrimSpace(rec.Body.String()) != tc.want {
+			t.Errorf("%s: got status %d and body %q, want 201 and %s", tc.body, rec.Code, rec.Body.String(), tc.want)
+		}
+	}
+}
+
+func TestServerEndToEnd(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	server := httptest.NewServer(NewServer(c))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/set", "application/json", strings.NewReader(`{"key":"k","value":{"n":1},"expiration":"1m"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("set: got status %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(server.URL + "/get?key=k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var value map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&value); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || value["n"] != 1.0 {
+		t.Fatalf("get: got status %d and %v", resp.StatusCode, value)
+	}
+
+	resp, err = http.Get(server.URL + "/unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown path: got status %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestTouchHandler(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+	c.Set("a", 1, time.Second)
+
+	if rec := serve(h, http.MethodPost, "/touch?key=a&ttl=1h", ""); rec.Code != http.StatusOK {
+		t.Fatalf("got status %d", rec.Code)
+	}
+	if ttl, _ := c.TTL("a"); ttl <= 59*time.Minute {
+		t.Fatalf("got TTL %s, want 1h", ttl)
+	}
+	if rec := serve(h, http.MethodPost, "/touch?key=missing&ttl=1h", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("missing key: got status %d, want 404", rec.Code)
+	}
+	if rec := serve(h, http.MethodPost, "/touch?key=a&ttl=later", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("bad ttl: got status %d, want 400", rec.Code)
+	}
+}
+
+func TestHeadCache(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+	c.Set("live", 1, 0)
+	c.Set("expired", 1, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	for _, tc := range []struct {
+		target string
+		code   int
+	}{
+		{"/cache?key=live", http.StatusOK},
+		{"/cache?key=expired", http.StatusNotFound},
+		{"/cache?key=absent", http.StatusNotFound},
+		{"/cache", http.StatusBadRequest},
+		{"/cache/live", http.StatusOK},
+		{"/cache/absent", http.StatusNotFound},
+	} {
+		rec := serve(h, http.MethodHead, tc.target, "")
+		if rec.Code != tc.code || rec.Body.Len() != 0 {
+			t.Errorf("HEAD %s: got status %d with %d body bytes, want %d and none", tc.target, rec.Code, rec.Body.Len(), tc.code)
+		}
+	}
+}
+
+func TestGetxHandler(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+	before := time.Now()
+	c.Set("brief", 1, time.Hour)
+	after := time.Now()
+	c.Set("forever", 2, 0)
+
+	_, expiresAt, ok := c.GetWithExpiry("brief")
+	if !ok || expiresAt.Before(before.Add(time.Hour)) || expiresAt.After(after.Add(time.Hour)) {
+		t.Fatalf("GetWithExpiry: got %s, %v", expiresAt, ok)
+	}
+	if _, expiresAt, ok := c.GetWithExpiry("forever"); !ok || !expiresAt.IsZero() {
+		t.Fatalf("GetWithExpiry of a permanent key: got %s, %v", expiresAt, ok)
+	}
+
+	var body map[string]interface{}
+	rec := serve(h, http.MethodGet, "/getx?key=brief", "")
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("/getx: status %d, %v", rec.Code, err)
+	}
+	if body["value"] != 1.0 || body["expires_at"] != expiresAt.Format(time.RFC3339Nano) {
+		t.Fatalf("/getx: got %v", body)
+	}
+
+	body = nil
+	rec = serve(h, http.MethodGet, "/getx?key=forever", "")
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := body["expires_at"]; found || body["value"] != 2.0 {
+		t.Fatalf("/getx of a permanent key: got %v", body)
+	}
+
+	if rec := serve(h, http.MethodGet, "/getx?key=missing", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("/getx of a missing key: status %d, want 404", rec.Code)
+	}
+}
+
+func TestStoredNullIsAHit(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+
+	c.Set("nil", nil, 0)
+	if value, ok := c.Get("nil"); !ok || value != nil {
+		t.Fatalf("Get: got %v, %v, want a nil hit", value, ok)
+	}
+	serve(h, http.MethodPost, "/set", `{"key":"null","value":null}`)
+	for _, key := range []string{"nil", "null"} {
+		rec := serve(h, http.MethodGet, "/get?key="+key, "")
+		if rec.Code != http.StatusOK || rec.Body.String() != "null\n" {
+			t.Errorf("/get of %s: status %d, body %q, want 200 null", key, rec.Code, rec.Body.String())
+		}
+	}
+	if stats := c.Stats(); stats.Hits != 3 || stats.Misses != 0 {
+		t.Fatalf("%d hits and %d misses, want null values counted as hits", stats.Hits, stats.Misses)
+	}
+}
+
+func TestFlushExpiredHandler(t *testing.T) {
+	c := NewCacheWithOptions(Options{LazyExpiration: true})
+	defer c.Close()
+	h := NewServer(c)
+	c.Set("a", 1, 10*time.Millisecond)
+	c.Set("b", 2, 10*time.Millisecond)
+	c.Set("c", 3, time.Minute)
+	c.Set("d", 4, 0)
+	time.Sleep(20 * time.Millisecond)
+
+	flush := func() int {
+		rec := serve(h, http.MethodPost, "/flush-expired", "")
+		var body map[string]int
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("/flush-expired: status %d, %v", rec.Code, err)
+		}
+		return body["evicted"]
+	}
+	if evicted := flush(); evicted != 2 {
+		t.Fatalf("evicted %d keys, want the 2 expired", evicted)
+	}
+	c.mutex.RLock()
+	stored := len(c.items)
+	c.mutex.RUnlock()
+	if stored != 2 {
+		t.Fatalf("%d items left stored, want 2", stored)
+	}
+	if evicted := flush(); evicted != 0 {
+		t.Fatalf("second flush evicted %d keys", evicted)
+	}
+}
+
+func TestCacheControlHeaders(t *testing.T) {
+	c := NewCacheWithOptions(Options{CacheControl: true})
+	defer c.Close()
+	h := NewServer(c)
+	c.Set("brief", 1, 90*time.Second)
+	c.Set("forever", 2, 0)
+	_, expiresAt, _ := c.GetWithExpiry("brief")
+
+	rec := serve(h, http.MethodGet, "/get?key=brief", "")
+	if cc := rec.Header().Get("Cache-Control"); cc != "max-age=89" {
+		t.Fatalf("Cache-Control %q, want the whole seconds left", cc)
+	}
+	if expires := rec.Header().Get("Expires"); expires != expiresAt.UTC().Format(http.TimeFormat) {
+		t.Fatalf("Expires %q", expires)
+	}
+	rec = serve(h, http.MethodGet, "/get?key=forever", "")
+	if cc := rec.Header().Get("Cache-Control"); cc != "" {
+		t.Fatalf("permanent key sent Cache-Control %q", cc)
+	}
+
+	plain := NewCache()
+	defer plain.Close()
+	plain.Set("brief", 1, time.Minute)
+	if cc := serve(NewServer(plain), http.MethodGet, "/get?key=brief", "").Header().Get("Cache-Control"); cc != "" {
+		t.Fatalf("Cache-Control %q sent without CacheControl", cc)
+	}
+}
+
+func TestWriteErrorPretty(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	h := NewServer(c)
+
+	plain := serve(h, http.MethodGet, "/get?key=missing", "")
+	pretty := serve(h, http.MethodGet, "/get?key=missing&pretty=1", "")
+	if strings.Contains(plain.Body.String(), "\n  ") {
+		t.Errorf("plain error body is indented: %q", plain.Body.String())
+	}
+	if !strings.Contains(pretty.Body.String(), "\n  \"error\": \"Key not found\"") {
+		t.Errorf("pretty error body is not indented: %q", pretty.Body.String())
+	}
+	if message, code := decodeError(t, pretty); message != "Key not found" || code != http.StatusNotFound {
+		t.Errorf("got %q and %d, want Key not found and 404", message, code)
+	}
+}
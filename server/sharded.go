@@ -0,0 +1,93 @@
+package main
+
+// DefaultShards is the number of shards the server splits each namespace into
+// unless told otherwise, see Options.Shards
+const DefaultShards = 16
+
+// split gives c the shards described by opts.Shards, dividing the capacity and
+// the byte and cost budgets between them. The shards share the event feed and
+// lock histograms of c, and have no eviction loop or sampler of their own:
+// those of c cover all of them.
+func (c *Cache) split(opts Options) {
+	n := opts.Shards
+	opts.Shards = 0
+	opts.StatsInterval = 0
+	opts.Capacity = ceilDiv(opts.Capacity, n)
+	opts.MaxBytes = ceilDiv(opts.MaxBytes, int64(n))
+	opts.MaxCost = ceilDiv(opts.MaxCost, int64(n))
+	c.parts = make([]*Cache, n)
+	for i := range c.parts {
+		part := newCache(opts)
+		close(part.done)
+		part.feed = c.feed
+		if c.locks != nil {
+			part.locks = &lockMetrics{wait: c.locks.wait, hold: c.locks.hold}
+		}
+		c.parts[i] = part
+	}
+}
+
+// ceilDiv divides a by b rounding up, so that shards never hold less in total than a
+func ceilDiv[T int | int64](a, b T) T {
+	return (a + b - 1) / b
+}
+
+// partIndex hashes key with FNV-1a to the index of the shard that owns it.
+// The hash is written out rather than taken from hash/fnv so that routing a
+// key does not allocate.
+func (c *Cache) partIndex(key string) int {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return int(hash % uint32(len(c.parts)))
+}
+
+// part returns the shard that owns key. It must only be called on a sharded cache.
+func (c *Cache) part(key string) *Cache {
+	return c.parts[c.partIndex(key)]
+}
+
+// owner returns the cache holding key: its shard, or c itself if c is not sharded
+func (c *Cache) owner(key string) *Cache {
+	if c.parts == nil {
+		return c
+	}
+	return c.part(key)
+}
+
+// shards returns the caches that hold the items of c: its shards, or c itself
+// if it is not sharded
+func (c *Cache) shards() []*Cache {
+	if c.parts == nil {
+		return []*Cache{c}
+	}
+	return c.parts
+}
+
+// lockAll takes the write lock of every shard, in order so that two callers
+// cannot deadlock, for operations that must see the whole cache at once.
+// Release them with unlockAll.
+func (c *Cache) lockAll() {
+	for _, shard := range c.shards() {
+		shard.lock()
+	}
+}
+
+// unlockAll releases the locks taken by lockAll
+func (c *Cache) unlockAll() {
+	for _, shard := range c.shards() {
+		shard.unlock()
+	}
+}
+
+// byPart groups keys by the index of the shard that owns them, keeping their order
+func (c *Cache) byPart(keys []string) [][]string {
+	groups := make([][]string, len(c.parts))
+	for _, key := range keys {
+		i := c.partIndex(key)
+		groups[i] = append(groups[i], key)
+	}
+	return groups
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShardedCacheRouting(t *testing.T) {
+	c := NewCacheWithOptions(Options{Capacity: 800, Shards: 8})
+	defer c.Close()
+
+	for i := 0; i < 400; i++ {
+		key := strconv.Itoa(i)
+		if c.partIndex(key) != c.partIndex(key) {
+			t.Fatalf("key %s moved between shards", key)
+		}
+		c.Set(key, i, 0)
+	}
+	if c.Len() != 400 || len(c.items) != 0 {
+		t.Fatalf("got %d keys, %d outside the shards; want 400, 0", c.Len(), len(c.items))
+	}
+	used := 0
+	for i, part := range c.parts {
+		if part.capacity != 100 {
+			t.Fatalf("shard %d holds %d keys, want 100", i, part.capacity)
+		}
+		if part.Len() > 0 {
+			used++
+		}
+		for key := range part.items {
+			if c.partIndex(key) != i {
+				t.Fatalf("key %s stored in shard %d, routed to %d", key, i, c.partIndex(key))
+			}
+		}
+	}
+	if used != len(c.parts) {
+		t.Fatalf("only %d of %d shards hold keys", used, len(c.parts))
+	}
+	if got, ok := c.Get("7"); !ok || got != 7 {
+		t.Fatalf("got %v, %t", got, ok)
+	}
+	if !c.Delete("7") || c.Len() != 399 {
+		t.Fatal("Delete did not remove the key from its shard")
+	}
+}
+
+func TestShardedCacheSpansShards(t *testing.T) {
+	c := NewCacheWithOptions(Options{Capacity: 400, Shards: 4})
+	defer c.Close()
+	items := make(map[string]CacheItem)
+	for i := 0; i < 20; i++ {
+		items["k"+strconv.Itoa(i)] = CacheItem{value: i}
+	}
+	c.SetMany(items)
+	c.SetWithTags("tagged1", 1, 0, "t")
+	c.SetWithTags("tagged2", 2, time.Minute, "t")
+
+	if got := c.Stats(); got.Size != 22 || got.Cost != 22 {
+		t.Fatalf("Stats: got %+v, want 22 keys", got)
+	}
+	keys := c.KeysWithPrefix("k1")
+	if want := []string{"k1", "k10", "k11", "k12", "k13", "k14", "k15", "k16", "k17", "k18", "k19"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("KeysWithPrefix: got %v, want %v", keys, want)
+	}
+	hits, misses := c.GetMultiDetailed([]string{"x", "k3", "y", "k4"})
+	if len(hits) != 2 || hits["k3"] != 3 || !reflect.DeepEqual(misses, []string{"x", "y"}) {
+		t.Fatalf("GetMultiDetailed: got %v and misses %v", hits, misses)
+	}
+	records, total := c.Dump(1, 3)
+	if total != 22 || len(records) != 3 || records[0].Key != "k1" || records[2].Key != "k11" {
+		t.Fatalf("Dump: got %v of %d", records, total)
+	}
+	if n := c.InvalidateTag("t"); n != 2 {
+		t.Fatalf("InvalidateTag removed %d keys, want 2", n)
+	}
+	if n := c.DeletePrefix("k1"); n != 11 || c.Len() != 9 {
+		t.Fatalf("DeletePrefix removed %d keys leaving %d, want 11 leaving 9", n, c.Len())
+	}
+
+	// A transaction sees and changes every shard at once
+	err := c.Transaction(func(tx *Tx) {
+		for i := 2; i < 10; i++ {
+			key := "k" + strconv.Itoa(i)
+			value, _ := tx.Get(key)
+			tx.Set(key, value.(int)*10, 0)
+		}
+		tx.Delete("k0")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c.Get("k9"); got != 90 || c.Has("k0") {
+		t.Fatalf("after the transaction got k9=%v and k0 present %t", got, c.Has("k0"))
+	}
+
+	path := filepath.Join(t.TempDir(), "cache.json")
+	if err := c.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewCacheWithOptions(Options{Capacity: 400, Shards: 3})
+	defer loaded.Close()
+	if err := loaded.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Keys(), c.Keys()) {
+		t.Fatalf("restored %v, want %v", loaded.Keys(), c.Keys())
+	}
+	c.Clear()
+	if c.Len() != 0 || len(c.Keys()) != 0 {
+		t.Fatalf("Clear left %v", c.Keys())
+	}
+}
+
+func TestShardedCacheBudgets(t *testing.T) {
+	c := NewCacheWithOptions(Options{Capacity: 8, Shards: 4, RejectWhenFull: true, LazyExpiration: true})
+	defer c.Close()
+	var evicted []string
+	c.OnEvict(func(key string, _ interface{}) { evicted = append(evicted, key) })
+	events, unsubscribe := c.feed.subscribe()
+	defer unsubscribe()
+
+	// Every shard holds two keys, so a third key routed to one is refused
+	byShard := make(map[int][]string)
+	for i := 0; len(byShard[0]) < 3; i++ {
+		key := strconv.Itoa(i)
+		byShard[c.partIndex(key)] = append(byShard[c.partIndex(key)], key)
+	}
+	first := byShard[0]
+	if err := c.TrySet(first[0], 0, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.TrySet(first[1], 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	err := c.TrySetMany(map[string]CacheItem{first[2]: {value: 2}, byShard[1][0]: {value: 3}})
+	if err != ErrCacheFull || c.Has(byShard[1][0]) {
+		t.Fatalf("TrySetMany into a full shard: got %v, stored %t; want ErrCacheFull and nothing stored", err, c.Has(byShard[1][0]))
+	}
+
+	// The expired key is reclaimed to make room and reported like any eviction
+	time.Sleep(2 * time.Millisecond)
+	if err := c.TrySet(first[2], 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(evicted, first[:1]) {
+		t.Fatalf("evicted %v, want %v", evicted, first[:1])
+	}
+	if event := <-events; event.Key != first[0] || event.Reason != "expired" {
+		t.Fatalf("got event %+v, want %s expired", event, first[0])
+	}
+}
+
+func TestShardedServer(t *testing.T) {
+	m := NewManager(Options{Shards: DefaultShards})
+	defer m.Close()
+	srv := httptest.NewServer(m)
+	defer srv.Close()
+
+	post := func(path, body string) *http.Response {
+		t.Helper()
+		resp, err := http.Post(srv.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	if resp := post("/set", `{"key":"a","value":1}`); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("/set: status %d", resp.StatusCode)
+	}
+	if resp := post("/mset", `[{"key":"b","value":2},{"key":"c","value":3},{"key":"d","value":4}]`); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("/mset: status %d", resp.StatusCode)
+	}
+
+	var keys []string
+	getJSON(t, srv.URL+"/keys", &keys)
+	if want := []string{"a", "b", "c", "d"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("/keys: got %v, want %v", keys, want)
+	}
+	var stats serverStats
+	getJSON(t, srv.URL+"/stats", &stats)
+	if stats.Size != 4 {
+		t.Fatalf("/stats: got %+v, want 4 keys", stats)
+	}
+	var got int
+	getJSON(t, srv.URL+"/get?key=c", &got)
+	if got != 3 {
+		t.Fatalf("/get: got %d, want 3", got)
+	}
+	if cache := m.Namespace(DefaultNamespace); len(cache.parts) != DefaultShards || cache.Len() != 4 {
+		t.Fatalf("namespace has %d shards holding %d keys, want %d holding 4", len(cache.parts), cache.Len(), DefaultShards)
+	}
+}
+
+// getJSON decodes the body of a GET of url into v
+func getJSON(t *testing.T, url string, v interface{}) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("%s: status %d, %v", url, resp.StatusCode, err)
+	}
+}
+
+// benchmarkParallel runs a mix of reads and writes from every goroutine
+func benchmarkParallel(b *testing.B, set func(string, int), get func(string)) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		set(keys[i], i)
+	}
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(next.Add(1)) * 7919
+		for pb.Next() {
+			key := keys[i%len(keys)]
+			if i%4 == 0 {
+				set(key, i)
+			} else {
+				get(key)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkSingleLock(b *testing.B) {
+	c := NewCache()
+	defer c.Close()
+	benchmarkParallel(b, func(k string, v int) { c.Set(k, v, 0) }, func(k string) { c.Get(k) })
+}
+
+func BenchmarkSharded(b *testing.B) {
+	c := NewCacheWithOptions(Options{Shards: DefaultShards})
+	defer c.Close()
+	benchmarkParallel(b, func(k string, v int) { c.Set(k, v, 0) }, func(k string) { c.Get(k) })
+}
+
+// benchmarkServer runs benchmarkParallel through the HTTP API of a manager
+// whose namespaces are split into shards, as main serves it
+func benchmarkServer(b *testing.B, shards int) {
+	m := NewManager(Options{Shards: shards})
+	defer m.Close()
+	benchmarkParallel(b, func(k string, v int) {
+		serve(m, http.MethodPost, "/set", `{"key":"`+k+`","value":`+strconv.Itoa(v)+`}`)
+	}, func(k string) {
+		serve(m, http.MethodGet, "/get?key="+k, "")
+	})
+}
+
+func BenchmarkServerSingleLock(b *testing.B) { benchmarkServer(b, 1) }
+
+func BenchmarkServerSharded(b *testing.B) { benchmarkServer(b, DefaultShards) }
@@ -0,0 +1,28 @@
+package main
+
+import "math"
+
+// EvictFraction evicts the given fraction of the items, least recently used
+// first, and returns how many were removed. A sharded cache evicts that
+// fraction of every shard. It is meant for shedding memory under pressure,
+// and runs the eviction callback for every removed item. A fraction outside
+// (0, 1], NaN included, evicts nothing and returns 0.
+func (c *Cache) EvictFraction(fraction float64) int {
+	if !(fraction > 0 && fraction <= 1) {
+		return 0
+	}
+	if c.parts != nil {
+		count := 0
+		for _, part := range c.parts {
+			count += part.EvictFraction(fraction)
+		}
+		return count
+	}
+	c.lock()
+	defer c.unlock()
+	count := int(math.Ceil(fraction * float64(len(c.items))))
+	for i := 0; i < count; i++ {
+		c.evictOldest()
+	}
+	return count
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestEvictFraction(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	for i := 0; i < 10; i++ {
+		c.Set(strconv.Itoa(i), i, 0)
+	}
+
+	for _, fraction := range []float64{0, -0.5, 1.5, math.NaN(), math.Inf(1)} {
+		if n := c.EvictFraction(fraction); n != 0 {
+			t.Errorf("EvictFraction(%g) evicted %d keys, want 0", fraction, n)
+		}
+	}
+	if c.Len() != 10 {
+		t.Fatalf("out of range fractions left %d keys, want 10", c.Len())
+	}
+
+	if n := c.EvictFraction(0.25); n != 3 {
+		t.Fatalf("EvictFraction(0.25) evicted %d keys, want 3", n)
+	}
+	for i := 0; i < 3; i++ {
+		if c.Has(strconv.Itoa(i)) {
+			t.Errorf("key %d should have been evicted first", i)
+		}
+	}
+	if n := c.EvictFraction(1); n != 7 || c.Len() != 0 {
+		t.Fatalf("EvictFraction(1) evicted %d keys and left %d", n, c.Len())
+	}
+}
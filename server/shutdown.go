@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// shutdownTimeout bounds how long in-flight requests may take to finish once shutdown starts
+const shutdownTimeout = 10 * time.Second
+
+// runServer serves srv until ctx is done and then shuts it down gracefully,
+// letting in-flight requests finish for up to timeout. Request contexts are
+// canceled as shutdown begins so that long-lived streams such as /events end.
+// It returns the error that stopped the server early, or the shutdown error.
+func runServer(ctx context.Context, srv *http.Server, timeout time.Duration) error {
+	requests, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	srv.BaseContext = func(net.Listener) context.Context { return requests }
+	srv.RegisterOnShutdown(cancelRequests)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
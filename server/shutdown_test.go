@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// freeAddr returns a loopback address nothing is listening on
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func TestRunServerShutsDownOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	addr := freeAddr(t)
+	srv := &http.Server{Addr: addr, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finish
+		io.WriteString(w, "done")
+	})}
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- runServer(ctx, srv, 5*time.Second) }()
+
+	responses := make(chan string, 1)
+	go func() {
+		for {
+			resp, err := http.Get("http://" + addr)
+			if err != nil {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			responses <- string(body)
+			return
+		}
+	}()
+
+	<-started
+	cancel()
+	select {
+	case err := <-stopped:
+		t.Fatalf("returned %v before the request in flight finished", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(finish)
+	if body := <-responses; body != "done" {
+		t.Fatalf("request in flight got %q", body)
+	}
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatalf("runServer returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runServer did not return after cancel")
+	}
+}
+
+func TestRunServerListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	srv := &http.Server{Addr: ln.Addr().String()}
+	err = runServer(context.Background(), srv, time.Second)
+	if err == nil || errors.Is(err, http.ErrServerClosed) {
+		t.Fatalf("got %v, want the listen error", err)
+	}
+}
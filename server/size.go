@@ -0,0 +1,32 @@
+package main
+
+import "encoding/json"
+
+// estimateSize approximates the memory held by a value: the length of strings
+// and byte slices, and the JSON encoding length of everything else
+func estimateSize(value interface{}) int64 {
+	switch v := value.(type) {
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
+}
+
+// enforceByteBudget evicts least recently used items until the estimated
+// size fits within maxBytes. The most recently used item is never evicted,
+// so a value larger than the whole budget is kept on its own.
+// The caller must hold the write lock.
+func (c *Cache) enforceByteBudget() {
+	if c.maxBytes <= 0 {
+		return
+	}
+	for c.bytes > c.maxBytes && c.order.Len() > 1 {
+		c.evictOne(c.order.Front())
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestByteBudget(t *testing.T) {
+	c := NewCacheWithOptions(Options{MaxBytes: 100})
+	defer c.Close()
+
+	c.Set("a", strings.Repeat("a", 40), 0)
+	c.Set("b", strings.Repeat("b", 40), 0)
+	c.Get("a")
+	c.Set("c", strings.Repeat("c", 40), 0)
+	if got := c.Keys(); !reflect.DeepEqual(got, []string{"a", "c"}) {
+		t.Fatalf("got keys %v, want b evicted as least recently used", got)
+	}
+	if stats := c.Stats(); stats.Bytes != 80 || stats.Evictions != 1 {
+		t.Fatalf("got %+v, want 80 bytes after 1 eviction", stats)
+	}
+
+	c.Set("a", "short", 0)
+	if stats := c.Stats(); stats.Bytes != 45 {
+		t.Fatalf("replacing a value: got %d bytes, want 45", stats.Bytes)
+	}
+	c.Delete("c")
+	if stats := c.Stats(); stats.Bytes != 5 {
+		t.Fatalf("after Delete: got %d bytes, want 5", stats.Bytes)
+	}
+
+	c.Set("huge", strings.Repeat("h", 500), 0)
+	if got := c.Keys(); !reflect.DeepEqual(got, []string{"huge"}) {
+		t.Fatalf("a value over the whole budget should be kept alone, got %v", got)
+	}
+}
+
+func TestMaxValueBytes(t *testing.T) {
+	c := NewCacheWithOptions(Options{MaxValueBytes: 10})
+	defer c.Close()
+	h := NewServer(c)
+
+	if err := c.TrySet("fits", strings.Repeat("x", 10), 0); err != nil {
+		t.Fatalf("value at the limit: %v", err)
+	}
+	if err := c.TrySet("big", strings.Repeat("x", 11), 0); !errors.Is(err, ErrValueTooLarge) {
+		t.Fatalf("value over the limit: got %v, want ErrValueTooLarge", err)
+	}
+	c.Set("big", strings.Repeat("x", 11), 0)
+	if c.Has("big") {
+		t.Fatal("Set stored a value over the limit")
+	}
+
+	if rec := serve(h, http.MethodPost, "/set", `{"key":"a","value":"`+strings.Repeat("x", 10)+`"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("/set at the limit: status %d", rec.Code)
+	}
+	rec := serve(h, http.MethodPost, "/set", `{"key":"b","value":"`+strings.Repeat("x", 11)+`"}`)
+	if message, _ := decodeError(t, rec); rec.Code != http.StatusRequestEntityTooLarge || !strings.HasPrefix(message, "value is too large") {
+		t.Fatalf("/set over the limit: status %d, message %q", rec.Code, message)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/cache/raw", strings.NewReader(strings.Repeat("x", 11)))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	raw := httptest.NewRecorder()
+	h.ServeHTTP(raw, req)
+	if raw.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("raw value over the limit: status %d", raw.Code)
+	}
+	if c.Has("b") || c.Has("raw") {
+		t.Fatal("an oversized value was stored")
+	}
+}
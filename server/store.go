@@ -0,0 +1,64 @@
+package main
+
+import "time"
+
+// Store is a slower backing tier the cache sits in front of, such as a
+// database. Load returns the value for key and the TTL to cache it for (zero
+// for none), or ok false if the store has no value either.
+type Store interface {
+	Load(key string) (value interface{}, ttl time.Duration, ok bool)
+	Save(key string, value interface{}, ttl time.Duration)
+}
+
+// storeSave is a stored value waiting to be saved to the backing store
+type storeSave struct {
+	key        string
+	value      interface{}
+	expiration int64 // Unix nanoseconds, 0 for none
+}
+
+// readThrough loads key from the backing store after a miss and caches what
+// it finds, returning the Unix nanosecond deadline it was cached with, 0 if
+// none. The store is called without holding the lock.
+func (c *Cache) readThrough(key string) (interface{}, int64, bool) {
+	value, ttl, ok := c.store.Load(key)
+	if !ok {
+		return nil, 0, false
+	}
+	c.lock()
+	defer c.unlock()
+	var expiration int64
+	if c.set(key, value, ttl) {
+		expiration = c.items[key].expiration
+	}
+	// The value came from the store, so it is not saved back to it
+	c.saves = nil
+	return value, expiration, true
+}
+
+// queueSave queues a value the cache stored under key for writeThrough when
+// write-through is on. Values the cache refuses never get here, so the tiers
+// agree. The caller must hold the write lock.
+func (c *Cache) queueSave(key string, value interface{}, expiration int64) {
+	if c.store != nil && c.writeThru {
+		c.saves = append(c.saves, storeSave{key: key, value: value, expiration: expiration})
+	}
+}
+
+// writeThrough saves the queued values to the backing store with their
+// remaining TTL. It is called by unlock once the lock is released.
+func (c *Cache) writeThrough(saves []storeSave) {
+	if len(saves) == 0 {
+		return
+	}
+	now := c.clock.Now().UnixNano()
+	for _, save := range saves {
+		var ttl time.Duration
+		if save.expiration > 0 {
+			if ttl = time.Duration(save.expiration - now); ttl <= 0 {
+				continue // expired since it was stored
+			}
+		}
+		c.store.Save(save.key, save.value, ttl)
+	}
+}
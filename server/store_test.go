@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mapStore is a Store backed by a map that counts its loads
+type mapStore struct {
+	mutex  sync.Mutex
+	values map[string]interface{}
+	ttls   map[string]time.Duration
+	loads  int
+}
+
+func newMapStore() *mapStore {
+	return &mapStore{values: make(map[string]interface{}), ttls: make(map[string]time.Duration)}
+}
+
+func (s *mapStore) Load(key string) (interface{}, time.Duration, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.loads++
+	value, ok := s.values[key]
+	return value, s.ttls[key], ok
+}
+
+func (s *mapStore) Save(key string, value interface{}, ttl time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.values[key] = value
+	s.ttls[key] = ttl
+}
+
+func TestReadThrough(t *testing.T) {
+	store := newMapStore()
+	store.Save("db", "row", time.Minute)
+	c := NewCacheWithOptions(Options{Store: store})
+	defer c.Close()
+
+	for i := 0; i < 3; i++ {
+		if value, ok := c.Get("db"); !ok || value != "row" {
+			t.Fatalf("read %d: got %v, %v", i, value, ok)
+		}
+	}
+	if store.loads != 1 {
+		t.Fatalf("store loaded %d times, want once before caching", store.loads)
+	}
+	if ttl, _ := c.TTL("db"); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("cached with TTL %s, want the store's", ttl)
+	}
+	if _, ok := c.Get("nowhere"); ok {
+		t.Fatal("a key in neither tier was found")
+	}
+}
+
+func TestWriteThroughOnlyOnSuccess(t *testing.T) {
+	store := newMapStore()
+	c := NewCacheWithOptions(Options{Store: store, WriteThrough: true, MaxValueBytes: 4})
+	defer c.Close()
+
+	c.Set("ok", "abc", time.Hour)
+	c.Set("big", "far too long for the limit", 0)
+	if err := c.TrySet("big", "also too long", 0); err == nil {
+		t.Fatal("TrySet accepted an oversized value")
+	}
+	if store.values["ok"] != "abc" || store.ttls["ok"] <= 59*time.Minute || store.ttls["ok"] > time.Hour {
+		t.Fatalf("store holds %v for %s, want the stored value", store.values["ok"], store.ttls["ok"])
+	}
+	if _, saved := store.values["big"]; saved {
+		t.Fatal("a rejected value was written through")
+	}
+
+	readOnly := newMapStore()
+	plain := NewCacheWithOptions(Options{Store: readOnly})
+	defer plain.Close()
+	plain.Set("k", 1, 0)
+	if len(readOnly.values) != 0 {
+		t.Fatal("Set wrote to the store without WriteThrough")
+	}
+}
+
+func TestWriteThroughEveryStore(t *testing.T) {
+	store := newMapStore()
+	c := NewCacheWithOptions(Options{Store: store, WriteThrough: true})
+	defer c.Close()
+
+	c.SetMany(map[string]CacheItem{"many": {value: 1}})
+	if err := c.TrySetMany(map[string]CacheItem{"trymany": {value: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	c.SetIfAbsent("absent", 1, 0)
+	c.SetIfPresent("absent", 2, 0)
+	if err := c.TrySetWithDeadline("deadline", 1, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	c.Increment("counter", 1)
+	c.Increment("counter", 2)
+	c.SetWithTags("tagged", 1, 0, "t")
+	c.SetWithCost("costly", 1, 2, 0)
+	c.CompareAndSwap("swapped", nil, 1, 0)
+	c.SetAndGetPrevious("previous", 1, 0)
+	c.GetOrSet("loaded", 1, 0)
+	if err := c.Transaction(func(tx *Tx) { tx.Set("tx", 1, 0) }); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]interface{}{
+		"many": 1, "trymany": 1, "absent": 2, "deadline": 1, "counter": int64(3), "tagged": 1,
+		"costly": 1, "swapped": 1, "previous": 1, "loaded": 1, "tx": 1,
+	} {
+		if got := store.values[key]; got != want {
+			t.Errorf("%s: store holds %#v, want %#v", key, got, want)
+		}
+	}
+	if ttl := store.ttls["deadline"]; ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("deadline saved with TTL %s, want what is left of an hour", ttl)
+	}
+}
+
+func TestStoreOverHTTP(t *testing.T) {
+	store := newMapStore()
+	store.Save("db", "row", time.Minute)
+	c := NewCacheWithOptions(Options{Store: store, WriteThrough: true})
+	defer c.Close()
+	h := NewServer(c)
+
+	rec := serve(h, http.MethodGet, "/get?key=db", "")
+	if got := strings.TrimSpace(rec.Body.String()); rec.Code != http.StatusOK || got != `"row"` {
+		t.Fatalf("/get of a stored key: got status %d and body %s", rec.Code, got)
+	}
+	if !c.Has("db") || store.ttls["db"] != time.Minute {
+		t.Fatal("/get did not cache what it read through, or saved it back")
+	}
+	if rec := serve(h, http.MethodGet, "/get?key=nowhere", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("/get of a key in neither tier: got status %d", rec.Code)
+	}
+
+	soon := time.Now().Add(time.Hour).Format(time.RFC3339)
+	for _, req := range []struct{ method, target, body string }{
+		{http.MethodPost, "/set", `{"key":"set","value":1}`},
+		{http.MethodPost, "/set", `{"key":"deadline","value":1,"expires_at":"` + soon + `"}`},
+		{http.MethodPost, "/mset", `[{"key":"mset","value":1}]`},
+		{http.MethodPost, "/add", `{"key":"add","value":1}`},
+		{http.MethodPost, "/replace", `{"key":"add","value":2}`},
+		{http.MethodPost, "/incr?key=counter", ""},
+	} {
+		if rec := serve(h, req.method, req.target, req.body); rec.Code >= 300 {
+			t.Fatalf("%s %s: got status %d, body %q", req.method, req.target, rec.Code, rec.Body.String())
+		}
+	}
+	for key, want := range map[string]interface{}{
+		"set": json.Number("1"), "deadline": json.Number("1"), "mset": json.Number("1"),
+		"add": json.Number("2"), "counter": int64(1),
+	} {
+		if got := store.values[key]; got != want {
+			t.Errorf("%s: store holds %#v, want %#v", key, got, want)
+		}
+	}
+	if _, saved := store.values["nowhere"]; saved {
+		t.Fatal("a miss was saved to the store")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import "time"
+
+// SetWithTags stores the value like Set and attaches tags to it, so it can be
+// removed along with every other item sharing a tag by InvalidateTag. Storing
+// the key again replaces its tags.
+func (c *Cache) SetWithTags(key string, value interface{}, expiration time.Duration, tags ...string) {
+	if c.parts != nil {
+		c.part(key).SetWithTags(key, value, expiration, tags...)
+		return
+	}
+	c.lock()
+	defer c.unlock()
+	if !c.set(key, value, expiration) {
+		return
+	}
+	item := c.items[key]
+	item.tags = append([]string(nil), tags...)
+	for _, tag := range item.tags {
+		keys := c.tagged[tag]
+		if keys == nil {
+			if c.tagged == nil {
+				c.tagged = make(map[string]map[string]struct{})
+			}
+			keys = make(map[string]struct{})
+			c.tagged[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+// InvalidateTag removes every item carrying tag and returns how many were
+// removed. Like Delete, it does not count or report them as evictions.
+func (c *Cache) InvalidateTag(tag string) int {
+	if c.parts != nil {
+		removed := 0
+		for _, part := range c.parts {
+			removed += part.InvalidateTag(tag)
+		}
+		return removed
+	}
+	c.lock()
+	defer c.unlock()
+	removed := 0
+	for key := range c.tagged[tag] {
+		c.removeItem(key, c.items[key])
+		removed++
+	}
+	return removed
+}
+
+// untag drops key from the index of every tag on item. The caller must hold the write lock.
+func (c *Cache) untag(key string, item *CacheItem) {
+	for _, tag := range item.tags {
+		delete(c.tagged[tag], key)
+		if len(c.tagged[tag]) == 0 {
+			delete(c.tagged, tag)
+		}
+	}
+	item.tags = nil
+}
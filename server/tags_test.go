@@ -0,0 +1,71 @@
+package main
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestInvalidateTag(t *testing.T) {
+	c := NewCacheWithCapacity(10)
+	defer c.Close()
+
+	c.SetWithTags("user:1", 1, 0, "user", "team:a")
+	c.SetWithTags("user:2", 2, 0, "user", "team:b")
+	c.SetWithTags("team:a", 3, time.Hour, "team:a")
+	c.Set("plain", 4, 0)
+
+	if removed := c.InvalidateTag("team:a"); removed != 2 {
+		t.Fatalf("team:a removed %d items, want 2", removed)
+	}
+	if keys := c.Keys(); !sort.StringsAreSorted(keys) || len(keys) != 2 || keys[0] != "plain" || keys[1] != "user:2" {
+		t.Fatalf("left %v, want [plain user:2]", keys)
+	}
+	// user:1 went with team:a, so the user tag now only holds user:2
+	if removed := c.InvalidateTag("user"); removed != 1 {
+		t.Fatalf("user removed %d items, want 1", removed)
+	}
+	if removed := c.InvalidateTag("unknown"); removed != 0 {
+		t.Fatalf("unknown tag removed %d items", removed)
+	}
+	if len(c.tagged) != 0 {
+		t.Fatalf("tag index not emptied: %v", c.tagged)
+	}
+}
+
+func TestSetReplacesTags(t *testing.T) {
+	c := NewCacheWithCapacity(2)
+	defer c.Close()
+
+	c.SetWithTags("a", 1, 0, "old")
+	c.SetWithTags("a", 2, 0, "new")
+	if removed := c.InvalidateTag("old"); removed != 0 {
+		t.Fatalf("replaced tag still removed %d items", removed)
+	}
+	c.Set("a", 3, 0)
+	if removed := c.InvalidateTag("new"); removed != 0 {
+		t.Fatalf("Set kept the tags of the old value")
+	}
+
+	// Evictions drop the evicted keys from the tag index too
+	c.SetWithTags("b", 1, 0, "t")
+	c.Set("c", 1, 0)
+	c.Set("d", 1, 0)
+	if len(c.tagged) != 0 {
+		t.Fatalf("evicted key left in the tag index: %v", c.tagged)
+	}
+}
+
+func TestSetWithTagsRejected(t *testing.T) {
+	c := NewCacheWithOptions(Options{MaxKeyLength: 4})
+	defer c.Close()
+
+	c.SetWithTags("", 1, 0, "t")
+	c.SetWithTags("too long", 2, 0, "t")
+	if c.Len() != 0 {
+		t.Fatalf("stored %d rejected keys", c.Len())
+	}
+	if len(c.tagged) != 0 {
+		t.Fatalf("rejected keys were tagged: %v", c.tagged)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// ServeText accepts connections on listener and answers the line-based text
+// protocol against cache until the listener is closed. Each line is one command:
+//
+//	SET key value [ttl]  stores value as a string, ttl is a duration such as 30s; replies OK
+//	GET key              replies VALUE followed by the value, or NOT_FOUND
+//	DEL key              replies DELETED or NOT_FOUND
+//	QUIT                 closes the connection
+//
+// Failures reply ERR followed by a message.
+func ServeText(listener net.Listener, cache *Cache) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go serveTextConn(conn, cache)
+	}
+}
+
+// serveTextConn answers the commands sent on conn until the client quits or disconnects
+func serveTextConn(conn io.ReadWriteCloser, cache *Cache) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	writer := bufio.NewWriter(conn)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if strings.EqualFold(fields[0], "QUIT") {
+			return
+		}
+		fmt.Fprint(writer, textCommand(cache, fields), "\r\n")
+		if err := writer.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// textCommand runs one command and returns its reply line
+func textCommand(cache *Cache, fields []string) string {
+	switch command, args := strings.ToUpper(fields[0]), fields[1:]; {
+	case command == "SET" && (len(args) == 2 || len(args) == 3):
+		expiration := cache.DefaultTTL()
+		if len(args) == 3 {
+			ttl, err := time.ParseDuration(args[2])
+			if err != nil {
+				return "ERR invalid ttl duration"
+			}
+			expiration = ttl
+		}
+		if err := cache.SetChecked(args[0], args[1], expiration); err != nil {
+			return "ERR " + err.Error()
+		}
+		return "OK"
+	case command == "GET" && len(args) == 1:
+		value, ok := cache.Get(args[0])
+		if !ok {
+			return "NOT_FOUND"
+		}
+		if s, isString := value.(string); isString {
+			return "VALUE " + s
+		}
+		// Values stored through the HTTP API are sent in their JSON form
+		data, err := json.Marshal(value)
+		if err != nil {
+			return "ERR " + err.Error()
+		}
+		return "VALUE " + string(data)
+	case command == "DEL" && len(args) == 1:
+		if !cache.Delete(args[0]) {
+			return "NOT_FOUND"
+		}
+		return "DELETED"
+	case command == "SET" || command == "GET" || command == "DEL":
+		return "ERR wrong number of arguments for " + command
+	default:
+		return "ERR unknown command " + fields[0]
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServeTextConn(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	c.Set("number", 42, 0)
+
+	client, server := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		serveTextConn(server, c)
+		close(done)
+	}()
+	defer client.Close()
+	replies := bufio.NewScanner(client)
+
+	tests := []struct{ command, reply string }{
+		{"SET greeting hello", "OK"},
+		{"get greeting", "VALUE hello"},
+		{"GET number", "VALUE 42"},
+		{"SET brief x 1m", "OK"},
+		{"SET brief x soon", "ERR invalid ttl duration"},
+		{"DEL greeting", "DELETED"},
+		{"DEL greeting", "NOT_FOUND"},
+		{"GET greeting", "NOT_FOUND"},
+		{"GET", "ERR wrong number of arguments for GET"},
+		{"PING", "ERR unknown command PING"},
+	}
+	for _, tt := range tests {
+		fmt.Fprintf(client, "%s\r\n", tt.command)
+		if !replies.Scan() {
+			t.Fatalf("%s: no reply: %v", tt.command, replies.Err())
+		}
+		// ScanLines drops the \r of each \r\n terminated reply
+		if got := replies.Text(); got != tt.reply {
+			t.Errorf("%s: got %q, want %q", tt.command, got, tt.reply)
+		}
+	}
+	if ttl, _ := c.TTL("brief"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("SET with a ttl left %s", ttl)
+	}
+
+	fmt.Fprint(client, "QUIT\r\n")
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("QUIT did not end the connection")
+	}
+}
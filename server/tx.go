@@ -0,0 +1,111 @@
+package main
+
+import "time"
+
+// Tx stages reads and writes for Transaction. Its methods may only be used
+// inside the function passed to Transaction.
+type Tx struct {
+	cache  *Cache
+	writes map[string]txWrite
+	order  []string // keys in the order they were first written
+}
+
+// txWrite is a staged Set or Delete
+type txWrite struct {
+	value      interface{}
+	expiration time.Duration
+	deleted    bool
+}
+
+// Transaction runs fn with the write lock held and then applies every Set and
+// Delete it staged at once, so readers see either all of them or none. If fn
+// panics nothing is applied. If any staged Set would be refused, the error
+// TrySet would give for it is returned and nothing is applied either. fn must
+// use tx rather than calling the cache, which would deadlock. A sharded cache
+// holds the lock of every shard meanwhile.
+func (c *Cache) Transaction(fn func(tx *Tx)) error {
+	c.lockAll()
+	defer c.unlockAll()
+	tx := &Tx{cache: c, writes: make(map[string]txWrite)}
+	fn(tx)
+	if err := tx.check(); err != nil {
+		return err
+	}
+	// Deletes go first so that the room they free is there for the sets
+	for _, key := range tx.order {
+		if write := tx.writes[key]; write.deleted {
+			owner := c.owner(key)
+			if item, found := owner.items[key]; found {
+				owner.removeItem(key, item)
+			}
+		}
+	}
+	for _, key := range tx.order {
+		if write := tx.writes[key]; !write.deleted {
+			c.owner(key).set(key, write.value, write.expiration)
+		}
+	}
+	return nil
+}
+
+// check returns the error that would make the cache refuse one of the staged
+// sets, counting the room the staged deletes free up
+func (tx *Tx) check() error {
+	added := make(map[*Cache]int)
+	for _, key := range tx.order {
+		write := tx.writes[key]
+		owner := tx.cache.owner(key)
+		_, found := owner.lookup(key)
+		if write.deleted {
+			if found {
+				added[owner]--
+			}
+			continue
+		}
+		if !found {
+			added[owner]++
+		}
+		if err := tx.cache.CheckKey(key); err != nil {
+			return err
+		}
+		if err := tx.cache.CheckValueSize(write.value); err != nil {
+			return err
+		}
+	}
+	for owner, n := range added {
+		if n > 0 && !owner.hasRoomFor(n) {
+			return ErrCacheFull
+		}
+	}
+	return nil
+}
+
+// Get returns the value for key as the transaction sees it, including its own staged writes
+func (tx *Tx) Get(key string) (interface{}, bool) {
+	if write, staged := tx.writes[key]; staged {
+		return write.value, !write.deleted
+	}
+	item, found := tx.cache.owner(key).lookup(key)
+	if !found {
+		return nil, false
+	}
+	return item.load(), true
+}
+
+// Set stages storing value under key, as Set would once the transaction commits
+func (tx *Tx) Set(key string, value interface{}, expiration time.Duration) {
+	tx.stage(key, txWrite{value: value, expiration: expiration})
+}
+
+// Delete stages removing key once the transaction commits
+func (tx *Tx) Delete(key string) {
+	tx.stage(key, txWrite{deleted: true})
+}
+
+// stage records write as the latest one for key
+func (tx *Tx) stage(key string, write txWrite) {
+	if _, staged := tx.writes[key]; !staged {
+		tx.order = append(tx.order, key)
+	}
+	tx.writes[key] = write
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransactionAllOrNothing(t *testing.T) {
+	c := NewCacheWithOptions(Options{Capacity: 2, RejectWhenFull: true})
+	defer c.Close()
+
+	err := c.Transaction(func(tx *Tx) {
+		tx.Set("a", 1, 0)
+		tx.Set("", 2, 0)
+	})
+	if !errors.Is(err, ErrEmptyKey) || c.Len() != 0 {
+		t.Fatalf("bad key: got %v with %d items, want ErrEmptyKey and nothing applied", err, c.Len())
+	}
+
+	c.Set("x", 1, 0)
+	c.Set("y", 2, 0)
+	if err := c.Transaction(func(tx *Tx) { tx.Set("a", 1, 0) }); !errors.Is(err, ErrCacheFull) {
+		t.Fatalf("full cache: got %v, want ErrCacheFull", err)
+	}
+	err = c.Transaction(func(tx *Tx) {
+		tx.Set("a", 1, 0)
+		tx.Delete("x")
+	})
+	if err != nil {
+		t.Fatalf("a delete freeing room: %v", err)
+	}
+	if !c.Has("a") || c.Has("x") || !c.Has("y") {
+		t.Fatalf("committed transaction left %v", c.Keys())
+	}
+
+	func() {
+		defer func() { recover() }()
+		c.Transaction(func(tx *Tx) {
+			tx.Delete("y")
+			panic("abort")
+		})
+	}()
+	if !c.Has("y") {
+		t.Fatal("a panicking transaction applied its delete")
+	}
+}
+
+func TestTransactionReadsOwnWrites(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+	c.Set("balance", 10, 0)
+
+	err := c.Transaction(func(tx *Tx) {
+		balance, _ := tx.Get("balance")
+		tx.Set("balance", balance.(int)-3, 0)
+		if value, _ := tx.Get("balance"); value != 7 {
+			t.Errorf("staged set not visible: %v", value)
+		}
+		tx.Delete("balance")
+		if _, ok := tx.Get("balance"); ok {
+			t.Error("staged delete not visible")
+		}
+		tx.Set("balance", 7, 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := c.Get("balance"); value != 7 {
+		t.Fatalf("balance = %v, want the last staged write", value)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"reflect"
+	"time"
+)
+
+// TypedCache wraps a Cache so values of type V can be stored and read
+// without type assertions at the call site
+type TypedCache[V any] struct {
+	cache *Cache
+}
+
+// NewTypedCache creates a typed view over an existing cache
+func NewTypedCache[V any](cache *Cache) *TypedCache[V] {
+	return &TypedCache[V]{cache: cache}
+}
+
+// Set stores a value of type V with an expiration time
+func (t *TypedCache[V]) Set(key string, value V, expiration time.Duration) {
+	t.cache.Set(key, value, expiration)
+}
+
+// Get retrieves the value for key. On a miss, or when the stored value is not
+// a V, it returns the zero value of V and false. A stored nil is a hit when V
+// is an interface type, which nil is a valid value of.
+func (t *TypedCache[V]) Get(key string) (V, bool) {
+	var zero V
+	value, ok := t.cache.Get(key)
+	if !ok {
+		return zero, false
+	}
+	if value == nil {
+		return zero, reflect.TypeOf(&zero).Elem().Kind() == reflect.Interface
+	}
+	typed, ok := value.(V)
+	if !ok {
+		return zero, false
+	}
+	return typed, true
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTypedCache(t *testing.T) {
+	c := NewCache()
+	defer c.Close()
+
+	strings := NewTypedCache[string](c)
+	strings.Set("name", "ada", time.Minute)
+	if got, ok := strings.Get("name"); !ok || got != "ada" {
+		t.Fatalf("got %q, %t; want ada", got, ok)
+	}
+
+	type user struct {
+		Name string
+		Age  int
+	}
+	users := NewTypedCache[user](c)
+	users.Set("u1", user{"grace", 85}, 0)
+	if got, ok := users.Get("u1"); !ok || got != (user{"grace", 85}) {
+		t.Fatalf("got %+v, %t", got, ok)
+	}
+
+	if got, ok := users.Get("missing"); ok || got != (user{}) {
+		t.Fatalf("miss: got %+v, %t; want the zero value", got, ok)
+	}
+	if got, ok := users.Get("name"); ok || got != (user{}) {
+		t.Fatalf("value of another type: got %+v, %t; want the zero value", got, ok)
+	}
+}
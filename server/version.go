@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+)
+
+// Build information, set at build time with for example
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+var (
+	version   = "dev"
+	commit    = "dev"
+	buildDate = "dev"
+)
+
+// report which build is running
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	jsonEncoder(w, r).Encode(struct {
+		Version   string `json:"version"`
+		Commit    string `json:"commit"`
+		BuildDate string `json:"build_date"`
+		GoVersion string `json:"go_version"`
+	}{version, commit, buildDate, runtime.Version()})
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestVersionHandler(t *testing.T) {
+	defer func(v, c, d string) { version, commit, buildDate = v, c, d }(version, commit, buildDate)
+	version, commit, buildDate = "v1.2.0", "abc123", "2024-01-02T03:04:05Z"
+
+	rec := httptest.NewRecorder()
+	versionHandler(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"version":    "v1.2.0",
+		"commit":     "abc123",
+		"build_date": "2024-01-02T03:04:05Z",
+		"go_version": runtime.Version(),
+	}
+	for field, value := range want {
+		if body[field] != value {
+			t.Errorf("%s = %q, want %q", field, body[field], value)
+		}
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type %q", ct)
+	}
+}